@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+
+	"github.com/ing-bank/kal/pkg/diff"
+	"github.com/ing-bank/kal/pkg/runner"
+	"github.com/ing-bank/kal/pkg/types"
+	"github.com/projectdiscovery/goflags"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	// diffExitDrift is the exit code of `kal diff` when the permissions drifted
+	diffExitDrift = 1
+	// diffExitError is the exit code of `kal diff` when the reports could not be compared
+	diffExitError = 2
+)
+
+// diffOptions are the options of the `kal diff` subcommand
+type diffOptions struct {
+	JSON    bool
+	NoColor bool
+	// FailOn is the drift exiting with diffExitDrift: any, added or none
+	FailOn string
+}
+
+// diffCommand runs the `kal diff old.json new.json` subcommand
+func diffCommand(args []string) {
+	o := &diffOptions{}
+
+	set := goflags.NewFlagSet()
+	set.SetDescription("kal diff compares the json output of two kal runs and reports the permission drift")
+	set.BoolVarP(&o.JSON, "json", "j", false, "output the diff as a json document")
+	set.BoolVarP(&o.NoColor, "no-color", "nc", false, "no color output")
+	set.StringVar(&o.FailOn, "fail-on", "any", "drift exiting with code 1: any, added (verbs) or none")
+
+	files := make([]string, 0, 2)
+	if len(args) > 0 {
+		// flags are accepted before and after the files
+		_ = set.Parse(args...)
+		for rest := set.CommandLine.Args(); len(rest) > 0; rest = set.CommandLine.Args() {
+			files = append(files, rest[0])
+			_ = set.CommandLine.Parse(rest[1:])
+		}
+	}
+
+	options.Output.NoColor = o.NoColor
+	if o.JSON {
+		options.Output.JSON = true
+	}
+	options.Configure()
+
+	if len(files) != 2 {
+		diffFatal("expected two reports, usage: kal diff [flags] old.json new.json")
+	}
+
+	if o.FailOn != "any" && o.FailOn != "added" && o.FailOn != "none" {
+		diffFatal("invalid fail-on value [" + o.FailOn + "], expected any, added or none")
+	}
+
+	oldReport, newReport := readDiffReport(files[0]), readDiffReport(files[1])
+	d := diff.Compare(oldReport, newReport)
+
+	var err error
+	if o.JSON {
+		err = d.WriteJSON(os.Stdout)
+	} else {
+		err = d.WriteText(os.Stdout, types.AU)
+	}
+	if err != nil {
+		diffFatal("could not write diff. error: " + err.Error())
+	}
+
+	if (o.FailOn == "any" && d.HasDrift()) || (o.FailOn == "added" && d.HasAddedVerbs()) {
+		os.Exit(diffExitDrift)
+	}
+}
+
+// readDiffReport reads the report of a run from a file, or from stdin with `-`
+func readDiffReport(path string) *runner.Report {
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		file, err = os.Open(path)
+		if err != nil {
+			diffFatal("could not open report. error: " + err.Error())
+		}
+		defer file.Close()
+	}
+
+	report, err := runner.ReadReport(file)
+	if err != nil {
+		diffFatal("could not read report [" + path + "]. error: " + err.Error())
+	}
+
+	return report
+}
+
+// diffFatal logs the error and exits with diffExitError, distinct from the drift exit code
+func diffFatal(msg string) {
+	gologger.Error().Msg(msg)
+	os.Exit(diffExitError)
+}
@@ -6,69 +6,155 @@ if the token has permission to interact with the resource.
 Usage:
 
 	kal [flags]
+	kal token inspect [flags]
+	kal diff [flags] old.json new.json
 
 Flags:
 KUBERNETES:
 
-	-token string          kubernetes api token
-	-url string            kubernetes api base url
-	-k, -insecure-tls      disable TLS verification
-	-n, -namespace string  namespace name
-	-nrl, -no-rate-limit   remove rate limit
-	-as string             user/service account to impersonate
-	-c, -config string     absolute path to kubeconfig file (default "$HOME/.kube/config")
+	-token string                  kubernetes api token (default $KAL_TOKEN)
+	-tf, -token-file string        file with the kubernetes api token
+	-ts, -token-stdin              read the kubernetes api token from stdin
+	-sad, -sa-dir string           service account token folder, with token, ca.crt and namespace files
+	-tsf, -tokens-file string      file with many tokens to analyze, one per line or a json/yaml list with labels
+	-vt, -verify-token             verify the token signature with the service account issuer keys of the cluster
+	-jwks-file string              verify the token signature with the keys of a jwks file, for offline use
+	-url string                    kubernetes api base url
+	-k, -insecure-tls              disable TLS verification
+	-client-cert string            path to a client certificate file for x509 authentication
+	-client-key string             path to the client certificate key file
+	-certificate-authority string  path to a CA bundle file verifying the server certificate
+	-tls-server-name string        server name used to verify the server certificate
+	-proxy-url string              http(s) or socks5 proxy url to reach the kubernetes api
+	-n, -namespace string[]        namespace names (comma separated)
+	-nf, -namespaces-file string   file with one namespace name per line
+	-A, -all-namespaces            analyze all namespaces, falling back to the provided ones when namespaces cannot be listed
+	-nrl, -no-rate-limit           remove rate limit
+	-qps int                       kubernetes client queries per second (default 5, 400 with -no-rate-limit)
+	-burst int                     kubernetes client burst (default 10, 400 with -no-rate-limit)
+	-as string                     user/service account to impersonate
+	-as-group string[]             group to impersonate (repeatable)
+	-as-uid string                 uid to impersonate
+	-as-extra string[]             extra attribute to impersonate in key=value format (repeatable)
+	-c, -config string             path to kubeconfig file (default $KUBECONFIG files merged or "$HOME/.kube/config")
+	-context string                kubeconfig context to use (default current context)
+	-cluster string                kubeconfig cluster to use
+	-user string                   kubeconfig user to use
+	-ac, -all-contexts             analyze every kubeconfig context
+	-contexts string[]             kubeconfig contexts to analyze (comma separated)
+	-pc, -parallel-contexts int    number of kubeconfig contexts analyzed at the same time (default 1)
+
+ENUMERATION:
+
+	-cc, -concurrency int             number of resources analyzed at the same time (default 10)
+	-mr, -max-requests int            maximum number of in-flight access review requests (0 for no limit) (default 50)
+	-av, -all-verbs                   test every api verb in every resource, instead of only the supported ones
+	-rr, -rules-review                enumerate using SelfSubjectRulesReview, falling back to SelfSubjectAccessReview for unsettled verbs
+	-nru, -non-resource-url string[]  non-resource urls to analyze (comma separated or file)
+	-snr, -skip-non-resource          skip the analysis of non-resource urls
 
 OUTPUT:
 
-	-v, -verbose       verbose output
-	-s, -silent        silent output
-	-sr, -show-reason  show reasons from kubernetes API response
-	-all               show all results, including ones without verbs allowed
-	-j, -json          output as json
-	-nc, -no-color     no color output
+	-v, -verbose          verbose output
+	-s, -silent           silent output
+	-sr, -show-reason     show reasons from kubernetes API response
+	-all                  show all results, including ones without verbs allowed
+	-j, -json             output each result as a json document
+	-jsonl, -json-lines   output each result as a json document in a single line
+	-ja, -json-aggregate  output all results in a single json document, with the execution metadata
+	-nc, -no-color        no color output
+	-risk-rules string    yaml file with custom risk rules extending the built-in catalogue
+	-graph-dot string     file to write the privilege escalation graph in graphviz dot format
+	-graph-json string    file to write the privilege escalation graph in json format
+	-sarif string         file to write the risk findings in sarif 2.1.0 format
+	-html string          file to write a self-contained html report, for offline review
+	-emit-rbac string     file to write the allowed permissions as rbac role and cluster role yaml
+	-expect string        yaml policy of the expected permissions per identity, exiting with code 2 when exceeded
+	-em, -expect-missing  with -expect, also report the expected permissions not allowed
+
+When KAL is not provided an authentication configuration it searches for the kubeconfig files of
+the `KUBECONFIG` environment variable or the `$HOME/.kube/config` file. Otherwise, it uses the
+provided information via CLI arguments. If KAL is executed inside a Kubernetes POD, it will use
+the data saved in the folder `/var/run/secrets/kubernetes.io/serviceaccount`.
+
+The `kal token inspect` subcommand decodes every claim of a JWT token, without verifying it, and
+reports legacy non-expiring tokens, expired tokens and, with `-check-bindings`, tokens bound to
+deleted pods or secrets. With `-verify-token` or `-jwks-file`, it verifies the token signature.
+
+The `kal diff` subcommand compares the json output of two runs, reporting the added and removed
+verbs per resource and namespace and the new resources. It exits with code 1 on drift, and 2 when
+the reports cannot be read.
 
-When KAL is not provided an authentication configuration it searches for the `$HOME/.kube/config`
-file. Otherwise, it uses the provided information via CLI arguments. If KAL is executed inside a
-Kubernetes POD, it will use the data saved in the folder `/var/run/secrets/kubernetes.io/serviceaccount`.
+With `-expect`, the allowed permissions are checked against a policy declaring the expected
+permissions of every identity. KAL exits with code 2 when a target has a permission not declared,
+could not be analyzed or, with `-expect-missing`, lacks a declared permission.
 */
 package main
 
 import (
+	"io"
 	"os"
 	"os/signal"
-	"path/filepath"
 
+	"github.com/ing-bank/kal/pkg/htmlreport"
+	"github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/ing-bank/kal/pkg/policy"
+	"github.com/ing-bank/kal/pkg/risk"
 	"github.com/ing-bank/kal/pkg/runner"
 	"github.com/ing-bank/kal/pkg/types"
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
+// policyExitViolation is the exit code when the allowed permissions violate the expected permissions
+// policy, distinct from the exit code 1 of the errors
+const policyExitViolation = 2
+
 var options *types.Options
 
 func init() {
 	options = &types.Options{
-		Kubernetes: &types.KubernetesOptions{},
-		Output:     &types.OutputOptions{},
+		Kubernetes:  &types.KubernetesOptions{},
+		Enumeration: &types.EnumerationOptions{},
+		Output:      &types.OutputOptions{},
 	}
 }
 
 func main() {
-	configureFlags()
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		tokenCommand(os.Args[2:])
+		return
+	}
 
-	if options.Kubernetes.KubeConfigPath != "" {
-		// read file and set options
-		setServerUrlFromKubeConfig(options.Kubernetes.KubeConfigPath)
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffCommand(os.Args[2:])
+		return
 	}
+
+	configureFlags()
+
 	options.Validate()
 	options.Configure()
 
-	printBannerAndDisclaimer()
+	if options.Output.Format() == types.TextOutput {
+		printBannerAndDisclaimer()
+	}
 
-	run := runner.FromOptions(options)
+	riskEngine := newRiskEngine()
+	expectPolicy := readExpectPolicy()
+	reportSink := runner.NewReportSink()
+
+	if options.Kubernetes.MultipleContexts() {
+		execContexts(reportSink, riskEngine, expectPolicy)
+		return
+	}
+
+	if options.Kubernetes.TokensFile != "" {
+		execTokens(reportSink, riskEngine, expectPolicy)
+		return
+	}
+
+	run := runner.FromOptions(options, runner.WithSink(collectSink(reportSink)))
 
 	// Setup graceful exits
 	c := make(chan os.Signal, 1)
@@ -81,7 +167,261 @@ func main() {
 		}
 	}()
 
-	run.Exec()
+	if _, err := run.Exec(); err != nil {
+		gologger.Fatal().Msgf("could not list permissions. error: %s\n", err)
+	}
+
+	evaluateRisk(reportSink, riskEngine)
+	emitRBAC(reportSink)
+	writeHTML(reportSink)
+	checkPolicy(reportSink, expectPolicy)
+	writeReport(reportSink)
+	exitOnPolicyViolation(reportSink)
+}
+
+// execContexts lists the permissions of many kubeconfig contexts
+func execContexts(reportSink *runner.ReportSink, riskEngine *risk.Engine, expectPolicy *policy.Policy) {
+	contexts := []string(options.Kubernetes.Contexts)
+	if options.Kubernetes.AllContexts {
+		var err error
+		contexts, err = runner.KubeConfigContexts(options.Kubernetes)
+		if err != nil {
+			gologger.Fatal().Msgf("could not list kubeconfig contexts. error: %s\n", err)
+		}
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		for range c {
+			gologger.Info().Msgf("CTRL+C pressed: Exiting\n")
+			os.Exit(1)
+		}
+	}()
+
+	contextErrors := runner.ExecContexts(
+		options,
+		contexts,
+		options.Kubernetes.ParallelContexts,
+		runner.WithSink(collectSink(reportSink)),
+	)
+	for _, contextError := range contextErrors {
+		reportSink.AddError(contextError.Target, contextError.Err)
+	}
+
+	evaluateRisk(reportSink, riskEngine)
+	emitRBAC(reportSink)
+	writeHTML(reportSink)
+	checkPolicy(reportSink, expectPolicy)
+	writeReport(reportSink)
+
+	if len(contextErrors) == len(contexts) {
+		gologger.Fatal().Msg("could not list permissions of any context")
+	}
+
+	exitOnPolicyViolation(reportSink)
+}
+
+// execTokens lists the permissions of every token of the tokens file, with a summary
+func execTokens(reportSink *runner.ReportSink, riskEngine *risk.Engine, expectPolicy *policy.Policy) {
+	tokens, err := kubernetes.ReadTokensFile(options.Kubernetes.TokensFile)
+	if err != nil {
+		gologger.Fatal().Msgf("could not read tokens file. error: %s\n", err)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		for range c {
+			gologger.Info().Msgf("CTRL+C pressed: Exiting\n")
+			os.Exit(1)
+		}
+	}()
+
+	tokenErrors := runner.ExecTokens(options, tokens, runner.WithSink(collectSink(reportSink)))
+	for _, tokenError := range tokenErrors {
+		reportSink.AddError(tokenError.Target, tokenError.Err)
+	}
+
+	evaluateRisk(reportSink, riskEngine)
+	emitRBAC(reportSink)
+	writeHTML(reportSink)
+	checkPolicy(reportSink, expectPolicy)
+
+	report := reportSink.Report()
+	report.Summary = runner.Summarize(report)
+
+	if options.Output.Format() == types.TextOutput {
+		gologger.Silent().Msg("")
+		if err := runner.WriteSummaryTable(os.Stdout, report.Summary); err != nil {
+			gologger.Error().Msgf("could not write summary. error: %s\n", err)
+		}
+	}
+
+	writeReport(reportSink)
+
+	if len(tokenErrors) == len(tokens) {
+		gologger.Fatal().Msg("could not list permissions of any token")
+	}
+
+	exitOnPolicyViolation(reportSink)
+}
+
+// newRiskEngine returns the risk engine with the built-in rules, extended by the custom rules file
+func newRiskEngine() *risk.Engine {
+	if options.Output.RiskRulesFile == "" {
+		return risk.NewEngine()
+	}
+
+	rules, err := risk.ReadRulesFile(options.Output.RiskRulesFile)
+	if err != nil {
+		gologger.Fatal().Msgf("could not read risk rules file. error: %s\n", err)
+	}
+
+	return risk.NewEngine(rules...)
+}
+
+// readExpectPolicy reads the policy of the expected permissions, nil when no file is provided
+func readExpectPolicy() *policy.Policy {
+	if options.Output.ExpectFile == "" {
+		return nil
+	}
+
+	p, err := policy.ReadFile(options.Output.ExpectFile)
+	if err != nil {
+		gologger.Fatal().Msgf("could not read expected permissions policy. error: %s\n", err)
+	}
+
+	return p
+}
+
+// evaluateRisk sets the risk findings and escalation graphs of the collected results, writing them
+// in text output, in the escalation graph files and in the sarif file
+func evaluateRisk(reportSink *runner.ReportSink, riskEngine *risk.Engine) {
+	runner.EvaluateRisk(reportSink.Report(), riskEngine)
+
+	if options.Output.Format() == types.TextOutput {
+		gologger.Silent().Msg("")
+		showTarget := options.Kubernetes.MultipleContexts() || options.Kubernetes.TokensFile != ""
+		if err := runner.WriteFindings(os.Stdout, reportSink.Report(), types.AU, showTarget); err != nil {
+			gologger.Error().Msgf("could not write risk findings. error: %s\n", err)
+		}
+
+		gologger.Silent().Msg("")
+		if err := runner.WriteEscalationPaths(os.Stdout, reportSink.Report(), types.AU, showTarget); err != nil {
+			gologger.Error().Msgf("could not write escalation paths. error: %s\n", err)
+		}
+	}
+
+	writeGraphFile(options.Output.EscalationDOTFile, reportSink.Report(), runner.WriteEscalationDOT)
+	writeGraphFile(options.Output.EscalationJSONFile, reportSink.Report(), runner.WriteEscalationJSON)
+	writeSARIF(reportSink, riskEngine)
+}
+
+// writeSARIF writes the risk findings as a SARIF log, when a file is provided
+func writeSARIF(reportSink *runner.ReportSink, riskEngine *risk.Engine) {
+	if options.Output.SARIFFile == "" {
+		return
+	}
+
+	file, err := os.Create(options.Output.SARIFFile)
+	if err != nil {
+		gologger.Error().Msgf("could not create sarif file. error: %s\n", err)
+		return
+	}
+	defer file.Close()
+
+	if err := runner.WriteSARIF(file, reportSink.Report(), riskEngine); err != nil {
+		gologger.Error().Msgf("could not write sarif log. error: %s\n", err)
+	}
+}
+
+// emitRBAC writes the allowed permissions as RBAC roles, when a file is provided
+func emitRBAC(reportSink *runner.ReportSink) {
+	if options.Output.RBACFile == "" {
+		return
+	}
+
+	file, err := os.Create(options.Output.RBACFile)
+	if err != nil {
+		gologger.Error().Msgf("could not create rbac file. error: %s\n", err)
+		return
+	}
+	defer file.Close()
+
+	if err := runner.WriteRBAC(file, reportSink.Report()); err != nil {
+		gologger.Error().Msgf("could not write rbac roles. error: %s\n", err)
+	}
+}
+
+// checkPolicy sets the policy violations of the collected results, writing them in text output
+func checkPolicy(reportSink *runner.ReportSink, expectPolicy *policy.Policy) {
+	if expectPolicy == nil {
+		return
+	}
+
+	runner.CheckPolicy(reportSink.Report(), expectPolicy, options.Output.ExpectMissing)
+
+	if options.Output.Format() == types.TextOutput {
+		gologger.Silent().Msg("")
+		showTarget := options.Kubernetes.MultipleContexts() || options.Kubernetes.TokensFile != ""
+		if err := runner.WritePolicyViolations(os.Stdout, reportSink.Report(), types.AU, showTarget); err != nil {
+			gologger.Error().Msgf("could not write policy violations. error: %s\n", err)
+		}
+	}
+}
+
+// exitOnPolicyViolation exits with policyExitViolation when a target violates the expected permissions
+func exitOnPolicyViolation(reportSink *runner.ReportSink) {
+	if runner.PolicyViolated(reportSink.Report()) {
+		gologger.Error().Msg("the allowed permissions do not match the expected permissions policy")
+		os.Exit(policyExitViolation)
+	}
+}
+
+// writeHTML writes the html report, when a file is provided
+func writeHTML(reportSink *runner.ReportSink) {
+	if options.Output.HTMLFile == "" {
+		return
+	}
+
+	file, err := os.Create(options.Output.HTMLFile)
+	if err != nil {
+		gologger.Error().Msgf("could not create html report file. error: %s\n", err)
+		return
+	}
+	defer file.Close()
+
+	if err := htmlreport.Write(file, reportSink.Report()); err != nil {
+		gologger.Error().Msgf("could not write html report. error: %s\n", err)
+	}
+}
+
+// writeGraphFile writes the escalation graphs of the report in a file, when a path is provided
+func writeGraphFile(path string, report *runner.Report, write func(io.Writer, *runner.Report) error) {
+	if path == "" {
+		return
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		gologger.Error().Msgf("could not create escalation graph file. error: %s\n", err)
+		return
+	}
+	defer file.Close()
+
+	if err := write(file, report); err != nil {
+		gologger.Error().Msgf("could not write escalation graph. error: %s\n", err)
+	}
+}
+
+// writeReport writes the aggregated report, when it is the selected output format
+func writeReport(reportSink *runner.ReportSink) {
+	if options.Output.Format() == types.JSONAggregateOutput {
+		if err := reportSink.WriteJSON(os.Stdout); err != nil {
+			gologger.Fatal().Msgf("could not write report. error: %s\n", err)
+		}
+	}
 }
 
 func configureFlags() {
@@ -89,68 +429,102 @@ func configureFlags() {
 	set.SetDescription(types.Banner)
 
 	setGroup(set, "kubernetes", "kubernetes",
-		set.StringVar(&options.Kubernetes.ApiToken, "token", "", "kubernetes api token"),
+		set.StringVar(&options.Kubernetes.ApiToken, "token", "", "kubernetes api token (default $KAL_TOKEN)"),
+		set.StringVarP(&options.Kubernetes.TokenFile, "token-file", "tf", "", "file with the kubernetes api token"),
+		set.BoolVarP(&options.Kubernetes.TokenStdin, "token-stdin", "ts", false, "read the kubernetes api token from stdin"),
+		set.StringVarP(&options.Kubernetes.ServiceAccountDir, "sa-dir", "sad", "", "service account token folder, with token, ca.crt and namespace files"),
+		set.StringVarP(&options.Kubernetes.TokensFile, "tokens-file", "tsf", "", "file with many tokens to analyze, one per line or a json/yaml list with labels"),
+		set.BoolVarP(&options.Kubernetes.VerifyToken, "verify-token", "vt", false, "verify the token signature with the service account issuer keys of the cluster"),
+		set.StringVar(&options.Kubernetes.JWKSFile, "jwks-file", "", "verify the token signature with the keys of a jwks file, for offline use"),
 		set.StringVar(&options.Kubernetes.ServerURL, "url", "", "kubernetes api base url"),
 		set.BoolVarP(&options.Kubernetes.InsecureTLS, "insecure-tls", "k", false, "disable TLS verification"),
-		set.StringVarP(&options.Kubernetes.Namespace, "namespace", "n", "", "namespace name"), // support multiple namespaces
+		set.StringVar(&options.Kubernetes.ClientCertificate, "client-cert", "", "path to a client certificate file for x509 authentication"),
+		set.StringVar(&options.Kubernetes.ClientKey, "client-key", "", "path to the client certificate key file"),
+		set.StringVar(&options.Kubernetes.CertificateAuthority, "certificate-authority", "", "path to a CA bundle file verifying the server certificate"),
+		set.StringVar(&options.Kubernetes.TLSServerName, "tls-server-name", "", "server name used to verify the server certificate"),
+		set.StringVar(&options.Kubernetes.ProxyURL, "proxy-url", "", "http(s) or socks5 proxy url to reach the kubernetes api"),
+		set.StringSliceVarP(&options.Kubernetes.Namespaces, "namespace", "n", nil, "namespace names (comma separated)", goflags.CommaSeparatedStringSliceOptions),
+		set.StringVarP(&options.Kubernetes.NamespacesFile, "namespaces-file", "nf", "", "file with one namespace name per line"),
+		set.BoolVarP(&options.Kubernetes.AllNamespaces, "all-namespaces", "A", false, "analyze all namespaces, falling back to the provided ones when namespaces cannot be listed"),
 		set.BoolVarP(&options.Kubernetes.NoRateLimit, "no-rate-limit", "nrl", false, "remove rate limit"),
+		set.IntVar(&options.Kubernetes.QPS, "qps", 0, "kubernetes client queries per second (default 5, 400 with -no-rate-limit)"),
+		set.IntVar(&options.Kubernetes.Burst, "burst", 0, "kubernetes client burst (default 10, 400 with -no-rate-limit)"),
 		set.StringVar(&options.Kubernetes.UserToImpersonate, "as", "", "user/service account to impersonate"),
+		set.StringSliceVar(&options.Kubernetes.GroupsToImpersonate, "as-group", nil, "group to impersonate (repeatable)", goflags.StringSliceOptions),
+		set.StringVar(&options.Kubernetes.UIDToImpersonate, "as-uid", "", "uid to impersonate"),
+		set.StringSliceVar(&options.Kubernetes.ExtraToImpersonate, "as-extra", nil, "extra attribute to impersonate in key=value format (repeatable)", goflags.StringSliceOptions),
+		set.StringVarP(&options.Kubernetes.KubeConfigPath, "config", "c", "", "path to kubeconfig file (default $KUBECONFIG files merged or \"$HOME/.kube/config\")"),
+		set.StringVar(&options.Kubernetes.KubeContext, "context", "", "kubeconfig context to use (default current context)"),
+		set.StringVar(&options.Kubernetes.KubeCluster, "cluster", "", "kubeconfig cluster to use"),
+		set.StringVar(&options.Kubernetes.KubeUser, "user", "", "kubeconfig user to use"),
+		set.BoolVarP(&options.Kubernetes.AllContexts, "all-contexts", "ac", false, "analyze every kubeconfig context"),
+		set.StringSliceVar(&options.Kubernetes.Contexts, "contexts", nil, "kubeconfig contexts to analyze (comma separated)", goflags.CommaSeparatedStringSliceOptions),
+		set.IntVarP(&options.Kubernetes.ParallelContexts, "parallel-contexts", "pc", 1, "number of kubeconfig contexts analyzed at the same time"),
 	)
 
-	if home := homedir.HomeDir(); home != "" {
-		set.
-			StringVarP(
-				&options.Kubernetes.KubeConfigPath,
-				"config",
-				"c",
-				filepath.Join(home, ".kube", "config"),
-				"absolute path to kubeconfig file",
-			).
-			Group("kubernetes")
-	} else {
-		set.
-			StringVarP(
-				&options.Kubernetes.KubeConfigPath,
-				"config",
-				"c",
-				"",
-				"absolute path to kubeconfig file",
-			).
-			Group("kubernetes")
-	}
+	setGroup(set, "enumeration", "enumeration",
+		set.IntVarP(&options.Enumeration.Concurrency, "concurrency", "cc", 10, "number of resources analyzed at the same time"),
+		set.IntVarP(&options.Enumeration.MaxRequests, "max-requests", "mr", 50, "maximum number of in-flight access review requests (0 for no limit)"),
+		set.BoolVarP(&options.Enumeration.AllVerbs, "all-verbs", "av", false, "test every api verb in every resource, instead of only the supported ones"),
+		set.BoolVarP(&options.Enumeration.RulesReview, "rules-review", "rr", false, "enumerate using SelfSubjectRulesReview, falling back to SelfSubjectAccessReview for unsettled verbs"),
+		set.StringSliceVarP(&options.Enumeration.NonResourceURLs, "non-resource-url", "nru", nil, "non-resource urls to analyze (comma separated or file)", goflags.FileCommaSeparatedStringSliceOptions),
+		set.BoolVarP(&options.Enumeration.SkipNonResource, "skip-non-resource", "snr", false, "skip the analysis of non-resource urls"),
+	)
 
 	setGroup(set, "output", "output",
 		set.BoolVarP(&options.Verbose, "verbose", "v", false, "verbose output"),
 		set.BoolVarP(&options.Silent, "silent", "s", false, "silent output"),
 		set.BoolVarP(&options.Output.ShowReason, "show-reason", "sr", false, "show reasons from kubernetes API response"),
 		set.BoolVar(&options.Output.ShowAll, "all", false, "show all results, including ones without verbs allowed"),
-		set.BoolVarP(&options.Output.JSON, "json", "j", false, "output as json"),
+		set.BoolVarP(&options.Output.JSON, "json", "j", false, "output each result as a json document"),
+		set.BoolVarP(&options.Output.JSONLines, "json-lines", "jsonl", false, "output each result as a json document in a single line"),
+		set.BoolVarP(&options.Output.JSONAggregate, "json-aggregate", "ja", false, "output all results in a single json document, with the execution metadata"),
 		set.BoolVarP(&options.Output.NoColor, "no-color", "nc", false, "no color output"),
+		set.StringVar(&options.Output.RiskRulesFile, "risk-rules", "", "yaml file with custom risk rules extending the built-in catalogue"),
+		set.StringVar(&options.Output.EscalationDOTFile, "graph-dot", "", "file to write the privilege escalation graph in graphviz dot format"),
+		set.StringVar(&options.Output.EscalationJSONFile, "graph-json", "", "file to write the privilege escalation graph in json format"),
+		set.StringVar(&options.Output.SARIFFile, "sarif", "", "file to write the risk findings in sarif 2.1.0 format"),
+		set.StringVar(&options.Output.HTMLFile, "html", "", "file to write a self-contained html report, for offline review"),
+		set.StringVar(&options.Output.RBACFile, "emit-rbac", "", "file to write the allowed permissions as rbac role and cluster role yaml"),
+		set.StringVar(&options.Output.ExpectFile, "expect", "", "yaml policy of the expected permissions per identity, exiting with code 2 when exceeded"),
+		set.BoolVarP(&options.Output.ExpectMissing, "expect-missing", "em", false, "with -expect, also report the expected permissions not allowed"),
 	)
 
 	_ = set.Parse()
 }
 
-func setGroup(set *goflags.FlagSet, groupName, description string, flags ...*goflags.FlagData) {
-	set.SetGroup(groupName, description)
-	for _, currentFlag := range flags {
-		currentFlag.Group(groupName)
+// collectSink returns the sink presenting the results in the selected output format, also
+// collecting them in the report for the risk evaluation
+func collectSink(reportSink *runner.ReportSink) runner.ResultSink {
+	if options.Output.Format() == types.JSONAggregateOutput {
+		return reportSink
 	}
+
+	return runner.MultiSink{outputSink(reportSink), reportSink}
 }
 
-func setServerUrlFromKubeConfig(configPath string) *kubernetes.Clientset {
-	config, err := clientcmd.BuildConfigFromFlags("", configPath)
-	if err != nil {
-		gologger.Fatal().Msgf("could not parse kube config file. error: %s\n", err)
+// outputSink returns the sink presenting the results in the selected output format
+func outputSink(reportSink *runner.ReportSink) runner.ResultSink {
+	switch options.Output.Format() {
+	case types.JSONOutput:
+		return runner.NewJSONSink(os.Stdout)
+	case types.JSONLinesOutput:
+		return runner.NewJSONLinesSink(os.Stdout)
+	case types.JSONAggregateOutput:
+		return reportSink
+	default:
+		textSink := runner.NewTextSink(os.Stdout, types.AU)
+		textSink.ShowReason = options.Output.ShowReason
+		textSink.ShowTarget = options.Kubernetes.MultipleContexts() || options.Kubernetes.TokensFile != ""
+		return textSink
 	}
-	options.Kubernetes.ServerURL = config.Host
+}
 
-	client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		gologger.Fatal().Msgf("could not create kubernetes client. error: %s\n", err)
+func setGroup(set *goflags.FlagSet, groupName, description string, flags ...*goflags.FlagData) {
+	set.SetGroup(groupName, description)
+	for _, currentFlag := range flags {
+		currentFlag.Group(groupName)
 	}
-
-	return client
 }
 
 func printBannerAndDisclaimer() {
@@ -0,0 +1,258 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ing-bank/kal/pkg/runner"
+	"github.com/logrusorgru/aurora/v4"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Change is the drift of the allowed verbs of a resource, sub-resource or non-resource URL in a namespace
+type Change struct {
+	// Target is the name of the target, empty when both runs analyzed a single target
+	Target       string   `json:"target,omitempty"`
+	Resource     string   `json:"resource"`
+	Namespace    string   `json:"namespace,omitempty"`
+	AddedVerbs   []string `json:"addedVerbs,omitempty"`
+	RemovedVerbs []string `json:"removedVerbs,omitempty"`
+}
+
+// Diff is the permission drift between two KAL runs
+type Diff struct {
+	Changes []*Change `json:"changes"`
+	// NewResources and RemovedResources are the resources found in a single run, like new CRDs
+	NewResources     []string `json:"newResources"`
+	RemovedResources []string `json:"removedResources"`
+}
+
+// permissionKey identifies a resource in a namespace of a target, ignoring its version
+type permissionKey struct {
+	target    string
+	resource  string
+	namespace string
+}
+
+// runPermissions holds the allowed verbs and the resources found in a run
+type runPermissions struct {
+	verbs     map[permissionKey]map[string]struct{}
+	resources map[string]struct{}
+	// complete is set when every target of the run reported all its results, with `-all`
+	complete bool
+}
+
+// Compare returns the drift of the allowed verbs from the old to the new report
+//
+// The targets are matched by name, or directly when both reports have a single target. The
+// versions of the resources are ignored, so an API version promotion is not a drift. The new and
+// removed resources are only compared when both runs list every result, with `-all`
+func Compare(oldReport, newReport *runner.Report) *Diff {
+	single := len(oldReport.Targets) == 1 && len(newReport.Targets) == 1
+
+	oldRun, newRun := collect(oldReport, single), collect(newReport, single)
+
+	d := &Diff{
+		Changes:          make([]*Change, 0),
+		NewResources:     make([]string, 0),
+		RemovedResources: make([]string, 0),
+	}
+
+	if oldRun.complete && newRun.complete {
+		d.NewResources = difference(newRun.resources, oldRun.resources)
+		d.RemovedResources = difference(oldRun.resources, newRun.resources)
+	}
+
+	keys := make(map[permissionKey]struct{})
+	for key := range oldRun.verbs {
+		keys[key] = struct{}{}
+	}
+	for key := range newRun.verbs {
+		keys[key] = struct{}{}
+	}
+
+	for key := range keys {
+		added := difference(newRun.verbs[key], oldRun.verbs[key])
+		removed := difference(oldRun.verbs[key], newRun.verbs[key])
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		d.Changes = append(d.Changes, &Change{
+			Target:       key.target,
+			Resource:     key.resource,
+			Namespace:    key.namespace,
+			AddedVerbs:   added,
+			RemovedVerbs: removed,
+		})
+	}
+
+	sort.Slice(d.Changes, func(i, j int) bool {
+		a, b := d.Changes[i], d.Changes[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Namespace < b.Namespace
+	})
+
+	return d
+}
+
+// collect returns the allowed verbs and resources of every target of a report
+func collect(report *runner.Report, single bool) *runPermissions {
+	run := &runPermissions{
+		verbs:     make(map[permissionKey]map[string]struct{}),
+		resources: make(map[string]struct{}),
+		complete:  len(report.Targets) > 0,
+	}
+
+	for _, targetReport := range report.Targets {
+		if targetReport.Target == nil || !targetReport.AllResults {
+			run.complete = false
+		}
+
+		target := ""
+		if !single && targetReport.Target != nil {
+			target = targetReport.Name()
+		}
+
+		for _, result := range targetReport.Results {
+			resource := resourceName(result.Resource)
+			run.resources[prefixTarget(target, resource)] = struct{}{}
+
+			if len(result.AllowedVerbs) == 0 {
+				continue
+			}
+
+			key := permissionKey{target: target, resource: resource, namespace: result.Namespace}
+			if _, ok := run.verbs[key]; !ok {
+				run.verbs[key] = make(map[string]struct{})
+			}
+			for _, verb := range result.AllowedVerbs {
+				run.verbs[key][verb] = struct{}{}
+			}
+		}
+	}
+
+	return run
+}
+
+// resourceName returns the name of a resource without its version, like `pods/exec` or `deployments.apps`
+func resourceName(resource *runner.Resource) string {
+	if resource.IsNonResource() {
+		return resource.NonResourceURL
+	}
+
+	name := resource.Name
+	if resource.SubResource != "" {
+		name += "/" + resource.SubResource
+	}
+
+	return schema.GroupResource{Group: resource.GroupName, Resource: name}.String()
+}
+
+func prefixTarget(target, s string) string {
+	if target == "" {
+		return s
+	}
+
+	return "[" + target + "] " + s
+}
+
+// difference returns the sorted items of a not in b
+func difference(a, b map[string]struct{}) []string {
+	items := make([]string, 0)
+	for item := range a {
+		if _, ok := b[item]; !ok {
+			items = append(items, item)
+		}
+	}
+	sort.Strings(items)
+
+	return items
+}
+
+// HasDrift checks if a verb or a resource was added or removed
+func (d *Diff) HasDrift() bool {
+	return len(d.Changes) > 0 || len(d.NewResources) > 0 || len(d.RemovedResources) > 0
+}
+
+// HasAddedVerbs checks if a verb was allowed in the new run only
+func (d *Diff) HasAddedVerbs() bool {
+	for _, change := range d.Changes {
+		if len(change.AddedVerbs) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteText writes the diff as colored lines, a nil aurora disables colors
+func (d *Diff) WriteText(writer io.Writer, au *aurora.Aurora) error {
+	if au == nil {
+		au = aurora.New(aurora.WithColors(false))
+	}
+
+	builder := &strings.Builder{}
+
+	if !d.HasDrift() {
+		builder.WriteString("no permission drift\n")
+	}
+
+	if len(d.Changes) > 0 {
+		builder.WriteString("PERMISSION CHANGES\n")
+	}
+	for _, change := range d.Changes {
+		scope := change.Namespace
+		if scope == "" {
+			scope = "CLUSTER_WIDE"
+			if strings.HasPrefix(change.Resource, "/") {
+				scope = "NON_RESOURCE"
+			}
+		}
+
+		fmt.Fprintf(builder, "%s [%s]", prefixTarget(change.Target, change.Resource), au.Blue(scope))
+		if len(change.AddedVerbs) > 0 {
+			fmt.Fprintf(builder, " added [%s]", au.Green(strings.Join(change.AddedVerbs, ",")))
+		}
+		if len(change.RemovedVerbs) > 0 {
+			fmt.Fprintf(builder, " removed [%s]", au.Red(strings.Join(change.RemovedVerbs, ",")))
+		}
+		builder.WriteRune('\n')
+	}
+
+	if len(d.NewResources) > 0 {
+		builder.WriteString("NEW RESOURCES\n")
+	}
+	for _, resource := range d.NewResources {
+		fmt.Fprintf(builder, "%s %s\n", au.Green("+"), resource)
+	}
+
+	if len(d.RemovedResources) > 0 {
+		builder.WriteString("REMOVED RESOURCES\n")
+	}
+	for _, resource := range d.RemovedResources {
+		fmt.Fprintf(builder, "%s %s\n", au.Red("-"), resource)
+	}
+
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// WriteJSON writes the diff as an indented JSON document
+func (d *Diff) WriteJSON(writer io.Writer) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ing-bank/kal/pkg/runner"
+)
+
+func report(allResults bool, results ...*runner.Result) *runner.Report {
+	return &runner.Report{Targets: []*runner.TargetReport{{Target: &runner.Target{Label: "ci", AllResults: allResults}, Results: results}}}
+}
+
+func TestCompare(t *testing.T) {
+	oldReport := report(true,
+		&runner.Result{
+			Resource:     &runner.Resource{GroupName: "apps", GroupVersion: "v1beta1", Name: "deployments", Namespaced: true},
+			Namespace:    "apps",
+			AllowedVerbs: []string{"get", "list"},
+		},
+		&runner.Result{
+			Resource:     &runner.Resource{GroupVersion: "v1", Name: "secrets", Namespaced: true},
+			Namespace:    "kube-system",
+			AllowedVerbs: []string{"list"},
+		},
+		&runner.Result{Resource: &runner.Resource{GroupVersion: "v1", Name: "nodes"}},
+	)
+
+	newReport := report(true,
+		&runner.Result{
+			Resource:     &runner.Resource{GroupName: "apps", GroupVersion: "v1", Name: "deployments", Namespaced: true},
+			Namespace:    "apps",
+			AllowedVerbs: []string{"get", "list", "patch"},
+		},
+		&runner.Result{
+			Resource:     &runner.Resource{GroupVersion: "v1", Name: "pods", SubResource: "exec", Namespaced: true},
+			Namespace:    "apps",
+			AllowedVerbs: []string{"create"},
+		},
+		&runner.Result{Resource: &runner.Resource{GroupVersion: "v1", Name: "secrets", Namespaced: true}, Namespace: "kube-system"},
+	)
+
+	d := Compare(oldReport, newReport)
+
+	expected := []*Change{
+		{Resource: "deployments.apps", Namespace: "apps", AddedVerbs: []string{"patch"}, RemovedVerbs: []string{}},
+		{Resource: "pods/exec", Namespace: "apps", AddedVerbs: []string{"create"}, RemovedVerbs: []string{}},
+		{Resource: "secrets", Namespace: "kube-system", AddedVerbs: []string{}, RemovedVerbs: []string{"list"}},
+	}
+	if !reflect.DeepEqual(d.Changes, expected) {
+		t.Errorf("unexpected changes %+v", d.Changes)
+	}
+
+	if !reflect.DeepEqual(d.NewResources, []string{"pods/exec"}) || !reflect.DeepEqual(d.RemovedResources, []string{"nodes"}) {
+		t.Errorf("unexpected resources %v %v", d.NewResources, d.RemovedResources)
+	}
+
+	if !d.HasDrift() || !d.HasAddedVerbs() {
+		t.Errorf("expected added verbs")
+	}
+
+	if d := Compare(oldReport, oldReport); d.HasDrift() {
+		t.Errorf("expected no drift, got %+v", d)
+	}
+}
+
+func TestCompareNewResources(t *testing.T) {
+	pods := &runner.Result{Resource: &runner.Resource{GroupVersion: "v1", Name: "pods"}, AllowedVerbs: []string{"get"}}
+	certificates := &runner.Result{
+		Resource:     &runner.Resource{GroupName: "cert-manager.io", GroupVersion: "v1", Name: "certificates"},
+		AllowedVerbs: []string{"get"},
+	}
+
+	// every resource has an allowed verb in the runs of a privileged token
+	d := Compare(report(true, pods), report(true, pods, certificates))
+	if !reflect.DeepEqual(d.NewResources, []string{"certificates.cert-manager.io"}) {
+		t.Errorf("expected the new resource with -all, got %v", d.NewResources)
+	}
+
+	// without -all, the resources without allowed verbs are not known
+	d = Compare(report(false, pods), report(true, pods, certificates))
+	if len(d.NewResources) != 0 || len(d.Changes) != 1 {
+		t.Errorf("expected only the allowed verbs without -all, got %+v", d)
+	}
+}
@@ -0,0 +1,191 @@
+package htmlreport
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/ing-bank/kal/pkg/risk"
+	"github.com/ing-bank/kal/pkg/runner"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+// page is the data rendered by the report template
+type page struct {
+	KALVersion string
+	Timestamp  time.Time
+	Targets    []*target
+}
+
+// target is the identity header, the permission matrix and the findings of an analyzed target
+type target struct {
+	Name       string
+	ServerURL  string
+	Context    string
+	Cluster    string
+	Identity   *runner.Identity
+	Namespaces []string
+	Error      string
+	// Verbs are the columns of the permission matrix, the API verbs first
+	Verbs    []string
+	Rows     []*row
+	Findings []*risk.Finding
+}
+
+// row is a resource, sub-resource or non-resource URL of the permission matrix in a namespace
+type row struct {
+	Resource string
+	Version  string
+	Scope    string
+	Cells    []*cell
+	Allowed  bool
+	// Reasons are the verbs with a reason or an evaluation error from the access reviews
+	Reasons []*runner.VerbResult
+}
+
+// cell is the result of the access review of a verb in a row
+type cell struct {
+	// State is allowed, denied, not-allowed or untested
+	State string
+	Title string
+}
+
+// Write renders the report as a single HTML file, with inline styles and scripts to work offline
+func Write(writer io.Writer, report *runner.Report) error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"join":          strings.Join,
+		"severityClass": func(s risk.Severity) string { return "severity-" + string(s) },
+	}).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+
+	p := &page{
+		KALVersion: report.KALVersion,
+		Timestamp:  report.Timestamp,
+		Targets:    make([]*target, 0, len(report.Targets)),
+	}
+
+	for _, targetReport := range report.Targets {
+		p.Targets = append(p.Targets, newTarget(targetReport))
+	}
+
+	return tmpl.Execute(writer, p)
+}
+
+// newTarget returns the rendered data of a target report
+func newTarget(targetReport *runner.TargetReport) *target {
+	t := &target{
+		Error:    targetReport.Error,
+		Findings: targetReport.Findings,
+		Rows:     make([]*row, 0, len(targetReport.Results)),
+	}
+
+	if targetReport.Target != nil {
+		t.Name = targetReport.Name()
+		t.ServerURL = targetReport.ServerURL
+		t.Context = targetReport.Context
+		t.Cluster = targetReport.Cluster
+		t.Identity = targetReport.Identity
+		t.Namespaces = targetReport.Namespaces
+	}
+
+	verbs := make(map[string]struct{})
+	for _, result := range targetReport.Results {
+		for _, verbResult := range result.VerbResults() {
+			verbs[verbResult.Verb] = struct{}{}
+		}
+	}
+	t.Verbs = orderedVerbs(verbs)
+
+	for _, result := range targetReport.Results {
+		t.Rows = append(t.Rows, newRow(result, t.Verbs))
+	}
+
+	sort.SliceStable(t.Rows, func(i, j int) bool {
+		if t.Rows[i].Resource != t.Rows[j].Resource {
+			return t.Rows[i].Resource < t.Rows[j].Resource
+		}
+		return t.Rows[i].Scope < t.Rows[j].Scope
+	})
+
+	return t
+}
+
+// newRow returns the permission matrix row of a result, with a cell per verb column
+func newRow(result *runner.Result, verbs []string) *row {
+	r := &row{
+		Version: result.Resource.GroupVersion,
+		Scope:   result.Namespace,
+		Cells:   make([]*cell, 0, len(verbs)),
+		Allowed: len(result.AllowedVerbs) > 0,
+		Reasons: make([]*runner.VerbResult, 0),
+	}
+
+	switch {
+	case result.Resource.IsNonResource():
+		r.Resource = result.Resource.NonResourceURL
+		r.Scope = "NON_RESOURCE"
+	case result.Namespace == "":
+		r.Scope = "CLUSTER_WIDE"
+	}
+
+	if !result.Resource.IsNonResource() {
+		resource := result.Resource.Name
+		if result.Resource.SubResource != "" {
+			resource += "/" + result.Resource.SubResource
+		}
+		r.Resource = schema.GroupResource{Group: result.Resource.GroupName, Resource: resource}.String()
+	}
+
+	verbResults := make(map[string]*runner.VerbResult)
+	for _, verbResult := range result.VerbResults() {
+		verbResults[verbResult.Verb] = verbResult
+		if verbResult.Reason != "" || verbResult.EvaluationError != "" {
+			r.Reasons = append(r.Reasons, verbResult)
+		}
+	}
+
+	for _, verb := range verbs {
+		verbResult, ok := verbResults[verb]
+		switch {
+		case !ok:
+			r.Cells = append(r.Cells, &cell{State: "untested", Title: verb + " not tested"})
+		case verbResult.Allowed:
+			r.Cells = append(r.Cells, &cell{State: "allowed", Title: verb + " allowed"})
+		case verbResult.Denied:
+			r.Cells = append(r.Cells, &cell{State: "denied", Title: verb + " explicitly denied"})
+		default:
+			r.Cells = append(r.Cells, &cell{State: "not-allowed", Title: verb + " not allowed"})
+		}
+	}
+
+	return r
+}
+
+// orderedVerbs returns the verbs in the order of the API verbs, followed by the others sorted,
+// like the verbs of the non-resource URLs
+func orderedVerbs(verbs map[string]struct{}) []string {
+	ordered := make([]string, 0, len(verbs))
+	for _, verb := range kubernetes.ApiVerbs {
+		if _, ok := verbs[verb]; ok {
+			ordered = append(ordered, verb)
+			delete(verbs, verb)
+		}
+	}
+
+	others := make([]string, 0, len(verbs))
+	for verb := range verbs {
+		others = append(others, verb)
+	}
+	sort.Strings(others)
+
+	return append(ordered, others...)
+}
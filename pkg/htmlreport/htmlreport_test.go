@@ -0,0 +1,73 @@
+package htmlreport
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ing-bank/kal/pkg/risk"
+	"github.com/ing-bank/kal/pkg/runner"
+	v1 "k8s.io/api/authorization/v1"
+)
+
+func review(verb string, allowed bool, reason string) *v1.SelfSubjectAccessReview {
+	return &v1.SelfSubjectAccessReview{
+		Spec:   v1.SelfSubjectAccessReviewSpec{ResourceAttributes: &v1.ResourceAttributes{Verb: verb}},
+		Status: v1.SubjectAccessReviewStatus{Allowed: allowed, Reason: reason},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	target := &runner.Target{
+		Label:      "ci <deployer>",
+		ServerURL:  "https://cluster:6443",
+		Identity:   &runner.Identity{Username: "system:serviceaccount:ci:deployer", Groups: []string{"system:serviceaccounts"}},
+		Namespaces: []string{"ci"},
+	}
+
+	report := &runner.Report{Targets: []*runner.TargetReport{{
+		Target: target,
+		Results: []*runner.Result{{
+			Target:    target,
+			Namespace: "ci",
+			Resource:  &runner.Resource{GroupVersion: "v1", Name: "pods", SubResource: "exec", Namespaced: true},
+			SelfSubjectAccessReviewResults: []*v1.SelfSubjectAccessReview{
+				review("create", true, `RBAC: allowed by RoleBinding "deployer/ci"`),
+				review("get", false, ""),
+			},
+			AllowedVerbs: []string{"create"},
+			DeniedVerbs:  []string{"get"},
+		}},
+		Findings: []*risk.Finding{{RuleID: "pod-exec", Title: "Execute commands in pods", Severity: risk.High, Permissions: []string{"create pods/exec"}, Namespaces: []string{"ci"}}},
+	}}}
+
+	buffer := &bytes.Buffer{}
+	if err := Write(buffer, report); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"<h2>ci &lt;deployer&gt;</h2>",
+		"<dt>Username</dt><dd>system:serviceaccount:ci:deployer</dd>",
+		`<th class="verb">create</th><th class="verb">get</th>`,
+		"<td>pods/exec</td>",
+		`<td class="verb allowed" title="create allowed" data-sort="allowed">`,
+		`<td class="verb not-allowed" title="get not allowed" data-sort="not-allowed">`,
+		"<li><strong>create</strong>: RBAC: allowed by RoleBinding &#34;deployer/ci&#34;</li>",
+		"<strong>Execute commands in pods</strong> (pod-exec)",
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("expected %s in the report", expected)
+		}
+	}
+}
+
+func TestOrderedVerbs(t *testing.T) {
+	verbs := map[string]struct{}{"put": {}, "get": {}, "create": {}, "post": {}, "bind": {}}
+	expected := []string{"create", "get", "bind", "post", "put"}
+
+	if ordered := orderedVerbs(verbs); !reflect.DeepEqual(ordered, expected) {
+		t.Errorf("expected verbs %v, got %v", expected, ordered)
+	}
+}
@@ -0,0 +1,251 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// LegacyTokenIssuer is the issuer of the legacy secret-based service account tokens
+const LegacyTokenIssuer = "kubernetes/serviceaccount"
+
+// legacyClaimPrefix is the prefix of the claims of the legacy secret-based service account tokens
+const legacyClaimPrefix = "kubernetes.io/serviceaccount/"
+
+// TokenInspection holds every claim of a JWT token, decoded without verifying the signature
+type TokenInspection struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyID,omitempty"`
+
+	Issuer    string     `json:"issuer,omitempty"`
+	Audiences []string   `json:"audiences,omitempty"`
+	Subject   string     `json:"subject,omitempty"`
+	IssuedAt  *time.Time `json:"issuedAt,omitempty"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Remaining is the lifetime left when the token was inspected, negative when it is expired
+	Remaining *time.Duration `json:"-"`
+	// ExpiresIn is the human-readable remaining lifetime
+	ExpiresIn string `json:"expiresIn,omitempty"`
+
+	Namespace          string `json:"namespace,omitempty"`
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	ServiceAccountUID  string `json:"serviceAccountUID,omitempty"`
+	PodName            string `json:"podName,omitempty"`
+	PodUID             string `json:"podUID,omitempty"`
+	NodeName           string `json:"nodeName,omitempty"`
+	NodeUID            string `json:"nodeUID,omitempty"`
+	SecretName         string `json:"secretName,omitempty"`
+
+	// LegacyClaims are the `kubernetes.io/serviceaccount/*` claims of secret-based tokens
+	LegacyClaims map[string]any `json:"legacyClaims,omitempty"`
+	// Claims are every claim of the token
+	Claims map[string]any `json:"claims"`
+
+	// Verification is the verification of the signature of the token, when it is enabled
+	Verification *TokenVerification `json:"verification,omitempty"`
+
+	// Findings are the weaknesses of the token, like being expired
+	Findings []string `json:"findings"`
+}
+
+// InspectToken decodes every claim of a JWT token and looks for weaknesses
+//
+// The token is not verified. Its lifetime is computed at the provided time
+func InspectToken(tk string, now time.Time) (*TokenInspection, error) {
+	if !isJWT(tk) {
+		return nil, errors.New("token is not a JWT")
+	}
+
+	parser := jwt.NewParser()
+
+	claims := jwt.MapClaims{}
+	token, _, err := parser.ParseUnverified(tk, claims)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse token: %w", err)
+	}
+
+	bound := &ServiceAccountTokenClaims{}
+	if _, _, err := parser.ParseUnverified(tk, bound); err != nil {
+		return nil, fmt.Errorf("could not parse token claims: %w", err)
+	}
+
+	inspection := &TokenInspection{
+		Issuer:             bound.Issuer,
+		Audiences:          bound.Audience,
+		Subject:            bound.Subject,
+		Namespace:          bound.Kubernetes.Namespace,
+		ServiceAccountName: bound.Kubernetes.ServiceAccount.Name,
+		ServiceAccountUID:  bound.Kubernetes.ServiceAccount.UID,
+		PodName:            bound.Kubernetes.Pod.Name,
+		PodUID:             bound.Kubernetes.Pod.UID,
+		NodeName:           bound.Kubernetes.Node.Name,
+		NodeUID:            bound.Kubernetes.Node.UID,
+		SecretName:         bound.Kubernetes.Secret.Name,
+		Claims:             claims,
+		Findings:           make([]string, 0),
+	}
+
+	inspection.Algorithm, _ = token.Header["alg"].(string)
+	inspection.KeyID, _ = token.Header["kid"].(string)
+
+	if bound.IssuedAt != nil {
+		inspection.IssuedAt = &bound.IssuedAt.Time
+	}
+
+	if bound.NotBefore != nil {
+		inspection.NotBefore = &bound.NotBefore.Time
+	}
+
+	if bound.ExpiresAt != nil {
+		remaining := bound.ExpiresAt.Sub(now)
+		inspection.ExpiresAt = &bound.ExpiresAt.Time
+		inspection.Remaining = &remaining
+		inspection.ExpiresIn = FormatDuration(remaining)
+	}
+
+	for key, value := range claims {
+		if !strings.HasPrefix(key, legacyClaimPrefix) {
+			continue
+		}
+
+		if inspection.LegacyClaims == nil {
+			inspection.LegacyClaims = make(map[string]any)
+		}
+		inspection.LegacyClaims[key] = value
+	}
+
+	if inspection.ServiceAccountName == "" && inspection.LegacyClaims != nil {
+		inspection.Namespace, _ = claims[legacyClaimPrefix+"namespace"].(string)
+		inspection.ServiceAccountName, _ = claims[legacyClaimPrefix+"service-account.name"].(string)
+		inspection.ServiceAccountUID, _ = claims[legacyClaimPrefix+"service-account.uid"].(string)
+		inspection.SecretName, _ = claims[legacyClaimPrefix+"secret.name"].(string)
+	}
+
+	inspection.findWeaknesses(now)
+
+	return inspection, nil
+}
+
+// IsLegacy returns if the token is a legacy secret-based service account token
+func (ti *TokenInspection) IsLegacy() bool {
+	return ti.Issuer == LegacyTokenIssuer || ti.LegacyClaims != nil
+}
+
+// IsExpired returns if the token was expired when it was inspected
+func (ti *TokenInspection) IsExpired() bool {
+	return ti.Remaining != nil && *ti.Remaining <= 0
+}
+
+// ClaimNames returns the sorted names of every claim
+func (ti *TokenInspection) ClaimNames() []string {
+	names := make([]string, 0, len(ti.Claims))
+	for name := range ti.Claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (ti *TokenInspection) findWeaknesses(now time.Time) {
+	if ti.IsLegacy() {
+		ti.Findings = append(ti.Findings, "legacy secret-based service account token")
+	}
+
+	if ti.ExpiresAt == nil {
+		ti.Findings = append(ti.Findings, "token never expires")
+	}
+
+	if ti.IsExpired() {
+		ti.Findings = append(ti.Findings, "token is expired")
+	}
+
+	if ti.NotBefore != nil && ti.NotBefore.After(now) {
+		ti.Findings = append(ti.Findings, "token is not valid yet")
+	}
+}
+
+// Verify stores the verification of the signature, adding a finding when the token is not
+// signed by the cluster or it was issued by another issuer
+func (ti *TokenInspection) Verify(verification *TokenVerification) {
+	ti.Verification = verification
+
+	switch {
+	case !verification.Verified:
+		ti.AddFinding("token is not signed by the service account issuer of the cluster")
+	case verification.IssuerMismatch():
+		ti.AddFinding("token is issued by another issuer than the cluster")
+	}
+}
+
+// AddFinding adds a weakness found outside of the token claims, like a deleted bound pod
+func (ti *TokenInspection) AddFinding(finding string) {
+	ti.Findings = append(ti.Findings, finding)
+}
+
+// FormatDuration returns a human-readable duration, like `2d3h` or `-15m` for past durations
+func FormatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%s%dd%dh", sign, days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%s%dh%dm", sign, hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%s%dm", sign, minutes)
+	default:
+		return fmt.Sprintf("%s%ds", sign, int(d.Seconds()))
+	}
+}
+
+// CheckBindings looks for the objects the token is bound to, adding a finding when the bound
+// pod or secret was deleted
+//
+// The client must be allowed to get pods and secrets in the namespace of the token
+func (ti *TokenInspection) CheckBindings(ctx context.Context, client k8s.Interface) error {
+	if ti.Namespace == "" {
+		return nil
+	}
+
+	if ti.PodName != "" {
+		pod, err := client.CoreV1().Pods(ti.Namespace).Get(ctx, ti.PodName, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			ti.AddFinding("token is bound to a deleted pod")
+		case err != nil:
+			return err
+		case ti.PodUID != "" && string(pod.UID) != ti.PodUID:
+			ti.AddFinding("token is bound to a deleted pod, replaced by a pod with the same name")
+		}
+	}
+
+	if ti.SecretName != "" {
+		_, err := client.CoreV1().Secrets(ti.Namespace).Get(ctx, ti.SecretName, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			ti.AddFinding("token is bound to a deleted secret")
+		case err != nil:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestInspectToken(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		findings []string
+	}{
+		{
+			name: "bound token",
+			claims: jwt.MapClaims{
+				"iss": "https://kubernetes.default.svc",
+				"sub": "system:serviceaccount:default:viewer",
+				"exp": now.Add(time.Hour).Unix(),
+				"kubernetes.io": map[string]any{
+					"namespace":      "default",
+					"pod":            map[string]any{"name": "web-1", "uid": "1"},
+					"serviceaccount": map[string]any{"name": "viewer", "uid": "2"},
+				},
+			},
+			findings: []string{},
+		},
+		{
+			name: "expired token",
+			claims: jwt.MapClaims{
+				"sub": "system:serviceaccount:default:viewer",
+				"exp": now.Add(-time.Hour).Unix(),
+			},
+			findings: []string{"token is expired"},
+		},
+		{
+			name: "legacy token",
+			claims: jwt.MapClaims{
+				"iss":                                    LegacyTokenIssuer,
+				"kubernetes.io/serviceaccount/namespace": "kube-system",
+				"kubernetes.io/serviceaccount/secret.name":          "old-token",
+				"kubernetes.io/serviceaccount/service-account.name": "old",
+			},
+			findings: []string{"legacy secret-based service account token", "token never expires"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte("key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			inspection, err := InspectToken(tk, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if inspection.ServiceAccountName == "" && tt.name != "expired token" {
+				t.Error("expected the service account name")
+			}
+
+			if len(inspection.Findings) != len(tt.findings) {
+				t.Fatalf("expected findings %v, got %v", tt.findings, inspection.Findings)
+			}
+
+			for i, finding := range tt.findings {
+				if inspection.Findings[i] != finding {
+					t.Errorf("expected finding %s, got %s", finding, inspection.Findings[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// OpenIDConfigurationPath is the path of the OIDC discovery document of the service account issuer
+	OpenIDConfigurationPath = "/.well-known/openid-configuration"
+	// JWKSPath is the default path of the service account issuer keys
+	JWKSPath = "/openid/v1/jwks"
+)
+
+// JSONWebKey is a public key of a JSON Web Key Set
+type JSONWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use,omitempty"`
+	Alg     string `json:"alg,omitempty"`
+	// N and E are the modulus and exponent of RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve, X and Y are the curve and coordinates of EC keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is the JSON Web Key Set of the service account issuer
+type JWKS struct {
+	// Issuer is the issuer of the tokens signed by the keys, empty when it is unknown
+	Issuer string       `json:"-"`
+	Keys   []JSONWebKey `json:"keys"`
+}
+
+// TokenVerification is the result of the verification of the signature of a token
+type TokenVerification struct {
+	// Verified is true when the token is signed by a key of the cluster
+	Verified bool   `json:"verified"`
+	KeyID    string `json:"keyID,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+	// ExpectedIssuer is the issuer of the cluster, empty when it is unknown
+	ExpectedIssuer string `json:"expectedIssuer,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// IssuerMismatch returns if the token was issued by another issuer than the cluster
+//
+// The legacy secret-based tokens signed by the cluster are accepted by the API server with the
+// LegacyTokenIssuer, so they are not a mismatch
+func (tv *TokenVerification) IssuerMismatch() bool {
+	if tv.Verified && tv.Issuer == LegacyTokenIssuer {
+		return false
+	}
+
+	return tv.ExpectedIssuer != "" && tv.Issuer != tv.ExpectedIssuer
+}
+
+// openIDConfiguration is the OIDC discovery document of the service account issuer
+type openIDConfiguration struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// FetchJWKS reads the issuer and the keys of the service account issuer from the Kubernetes API
+//
+// The keys are read from the API server, using the path of the `jwks_uri`, as the issuer url
+// may not be reachable
+func FetchJWKS(ctx context.Context, client rest.Interface) (*JWKS, error) {
+	body, err := client.Get().AbsPath(OpenIDConfigurationPath).Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("could not get openid configuration: %w", err)
+	}
+
+	configuration := &openIDConfiguration{}
+	if err := json.Unmarshal(body, configuration); err != nil {
+		return nil, fmt.Errorf("could not parse openid configuration: %w", err)
+	}
+
+	jwksPath := JWKSPath
+	if jwksURI, err := url.Parse(configuration.JWKSURI); err == nil && jwksURI.Path != "" {
+		jwksPath = jwksURI.Path
+	}
+
+	body, err = client.Get().AbsPath(jwksPath).Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("could not get jwks: %w", err)
+	}
+
+	jwks, err := ParseJWKS(body)
+	if err != nil {
+		return nil, err
+	}
+	jwks.Issuer = configuration.Issuer
+
+	return jwks, nil
+}
+
+// ReadJWKSFile reads the keys of the service account issuer from a file, for offline verification
+func ReadJWKSFile(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
+
+// ParseJWKS parses a JSON Web Key Set
+func ParseJWKS(data []byte) (*JWKS, error) {
+	jwks := &JWKS{}
+	if err := json.Unmarshal(data, jwks); err != nil {
+		return nil, fmt.Errorf("could not parse jwks: %w", err)
+	}
+
+	if len(jwks.Keys) == 0 {
+		return nil, errors.New("jwks has no keys")
+	}
+
+	return jwks, nil
+}
+
+// VerifyToken checks if a token is signed by one of the keys
+//
+// Only the signature and the issuer are checked, expired tokens are reported by InspectToken
+func (jwks *JWKS) VerifyToken(tk string) *TokenVerification {
+	verification := &TokenVerification{ExpectedIssuer: jwks.Issuer}
+
+	token, err := jwt.Parse(
+		tk,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			verification.KeyID = kid
+
+			// without a key id, every key of the set is tried
+			keySet := jwt.VerificationKeySet{}
+			for _, key := range jwks.Keys {
+				if kid != "" && key.KeyID != kid {
+					continue
+				}
+
+				publicKey, err := key.PublicKey()
+				if err != nil {
+					return nil, err
+				}
+				keySet.Keys = append(keySet.Keys, publicKey)
+			}
+
+			if len(keySet.Keys) == 0 {
+				return nil, fmt.Errorf("no key with id [%s]", kid)
+			}
+
+			return keySet, nil
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithoutClaimsValidation(),
+	)
+
+	if token != nil {
+		verification.Issuer, _ = token.Claims.GetIssuer()
+	}
+
+	if err != nil {
+		verification.Error = err.Error()
+		return verification
+	}
+
+	verification.Verified = token.Valid
+
+	return verification
+}
+
+// PublicKey returns the public key of a RSA or EC JSON Web Key
+func (key *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch key.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve [%s]", key.Curve)
+		}
+
+		x, err := decodeBigInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type [%s]", key.KeyType)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
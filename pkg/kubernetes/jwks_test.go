@@ -0,0 +1,96 @@
+package kubernetes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestJWKSVerifyToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forgedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := &JWKS{
+		Issuer: "https://kubernetes.default.svc",
+		Keys: []JSONWebKey{
+			{KeyType: "RSA", KeyID: "rsa", N: encodeBigInt(rsaKey.N), E: encodeBigInt(big.NewInt(int64(rsaKey.E)))},
+			{KeyType: "EC", KeyID: "ec", Curve: "P-256", X: encodeBigInt(ecKey.X), Y: encodeBigInt(ecKey.Y)},
+		},
+	}
+
+	signClaims := func(method jwt.SigningMethod, kid string, claims jwt.MapClaims, key any) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+
+		tk, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return tk
+	}
+
+	sign := func(method jwt.SigningMethod, kid, issuer string, key any) string {
+		return signClaims(method, kid, jwt.MapClaims{"iss": issuer, "sub": "system:serviceaccount:default:viewer"}, key)
+	}
+
+	legacyClaims := jwt.MapClaims{
+		"iss":                                    LegacyTokenIssuer,
+		"sub":                                    "system:serviceaccount:default:viewer",
+		"kubernetes.io/serviceaccount/namespace": "default",
+		"kubernetes.io/serviceaccount/secret.name":          "viewer-token",
+		"kubernetes.io/serviceaccount/service-account.name": "viewer",
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		verified       bool
+		issuerMismatch bool
+	}{
+		{"rsa", sign(jwt.SigningMethodRS256, "rsa", jwks.Issuer, rsaKey), true, false},
+		{"ec", sign(jwt.SigningMethodES256, "ec", jwks.Issuer, ecKey), true, false},
+		{"forged", sign(jwt.SigningMethodRS256, "rsa", jwks.Issuer, forgedKey), false, false},
+		{"unknown key", sign(jwt.SigningMethodRS256, "other", jwks.Issuer, rsaKey), false, false},
+		{"other issuer", sign(jwt.SigningMethodRS256, "rsa", "https://other", rsaKey), true, true},
+		{"legacy", signClaims(jwt.SigningMethodRS256, "rsa", legacyClaims, rsaKey), true, false},
+		{"forged legacy", signClaims(jwt.SigningMethodRS256, "rsa", legacyClaims, forgedKey), false, true},
+		{"hmac", sign(jwt.SigningMethodHS256, "rsa", jwks.Issuer, []byte("key")), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verification := jwks.VerifyToken(tt.token)
+
+			if verification.Verified != tt.verified {
+				t.Errorf("expected verified %t, got %t (%s)", tt.verified, verification.Verified, verification.Error)
+			}
+
+			if verification.IssuerMismatch() != tt.issuerMismatch {
+				t.Errorf("expected issuer mismatch %t", tt.issuerMismatch)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package kubernetes
 
 import (
-	"reflect"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,47 +13,66 @@ type ServiceAccountTokenClaims struct {
 		Namespace string
 		Node      struct {
 			Name string
+			UID  string
 		}
 		Pod struct {
 			Name string
+			UID  string
+		}
+		Secret struct {
+			Name string
+			UID  string
 		}
 		ServiceAccount struct {
 			Name string
+			UID  string
 		}
 	} `json:"kubernetes.io"`
 	jwt.RegisteredClaims
 }
 
-// GrabNamespaceFromToken returns the namespace stored in a Kubernetes JWT Claim
-func GrabNamespaceFromToken(sa string) (ns string) {
-	ns = "default"
-	if !isJWT(sa) {
-		gologger.Warning().Msg("service account token is probably not a JWT")
-		// always return the default namespace
-		return
+// NamespaceFromToken returns the namespace stored in the claims of a Kubernetes service account token
+//
+// Bound tokens store it in the `kubernetes.io` claim, legacy secret-based tokens in the
+// `kubernetes.io/serviceaccount/namespace` claim. It returns an empty namespace for other
+// tokens, like OIDC user tokens, and for tokens that are not a JWT
+func NamespaceFromToken(tk string) string {
+	if !isJWT(tk) {
+		return ""
 	}
 
-	token, err := jwt.ParseWithClaims(sa, &ServiceAccountTokenClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte("a"), nil
-	})
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tk, claims); err != nil {
+		gologger.Debug().Msgf("could not parse token claims. err: %v\n", err)
+		return ""
+	}
 
-	if err != nil && reflect.TypeOf(err) == reflect.TypeOf(jwt.ErrTokenSignatureInvalid) {
-		gologger.Error().Msgf("could not parse service account token claims. err: %v\n", err)
-		return
+	saClaims := &ServiceAccountTokenClaims{}
+	_, _, _ = jwt.NewParser().ParseUnverified(tk, saClaims)
+
+	ns := saClaims.Kubernetes.Namespace
+	if ns == "" {
+		ns, _ = claims["kubernetes.io/serviceaccount/namespace"].(string)
 	}
 
-	claims := token.Claims.(*ServiceAccountTokenClaims)
-	ns = claims.Kubernetes.Namespace
+	if ns != "" {
+		gologger.Debug().Msgf("found service account token namespace: %v\n", ns)
+	}
 
+	return ns
+}
+
+// GrabNamespaceFromToken returns the namespace stored in a Kubernetes JWT Claim, or `default`
+// when the token has no namespace
+//
+// Deprecated: use NamespaceFromToken, returning an empty namespace for the tokens without one
+func GrabNamespaceFromToken(sa string) (ns string) {
+	ns = NamespaceFromToken(sa)
 	if ns == "" {
-		token, _ = jwt.Parse(sa, func(t *jwt.Token) (interface{}, error) {
-			return []byte("a"), nil
-		})
-		ns = token.Claims.(jwt.MapClaims)["kubernetes.io/serviceaccount/namespace"].(string)
+		ns = "default"
 	}
 
-	gologger.Debug().Msgf("found service account token namespace: %v\n", ns)
-	return
+	return ns
 }
 
 func isJWT(tk string) bool {
@@ -65,3 +83,51 @@ func isJWT(tk string) bool {
 
 	return true
 }
+
+// TokenIdentity is the identity of the authentication of a token, read from its claims
+type TokenIdentity struct {
+	Username string
+	UID      string
+	Groups   []string
+}
+
+// IdentityFromToken returns the identity of a token from its unverified claims
+//
+// Service account tokens, bound or legacy secret-based, are named `system:serviceaccount:<ns>:<name>`
+// with the service account groups. Other JWT tokens are named after their subject. It returns nil
+// when the token is not a JWT
+func IdentityFromToken(tk string) *TokenIdentity {
+	if !isJWT(tk) {
+		return nil
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tk, claims); err != nil {
+		return nil
+	}
+
+	saClaims := &ServiceAccountTokenClaims{}
+	_, _, _ = jwt.NewParser().ParseUnverified(tk, saClaims)
+
+	namespace, name, uid := saClaims.Kubernetes.Namespace, saClaims.Kubernetes.ServiceAccount.Name, saClaims.Kubernetes.ServiceAccount.UID
+	if name == "" {
+		namespace, _ = claims["kubernetes.io/serviceaccount/namespace"].(string)
+		name, _ = claims["kubernetes.io/serviceaccount/service-account.name"].(string)
+		uid, _ = claims["kubernetes.io/serviceaccount/service-account.uid"].(string)
+	}
+
+	if namespace != "" && name != "" {
+		return &TokenIdentity{
+			Username: "system:serviceaccount:" + namespace + ":" + name,
+			UID:      uid,
+			Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"},
+		}
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil
+	}
+
+	return &TokenIdentity{Username: subject}
+}
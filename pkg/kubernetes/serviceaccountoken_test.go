@@ -0,0 +1,63 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNamespaceFromToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		namespace string
+	}{
+		{
+			name: "bound token",
+			claims: jwt.MapClaims{
+				"sub":           "system:serviceaccount:apps:viewer",
+				"kubernetes.io": map[string]any{"namespace": "apps"},
+			},
+			namespace: "apps",
+		},
+		{
+			name: "legacy token",
+			claims: jwt.MapClaims{
+				"iss":                                    LegacyTokenIssuer,
+				"kubernetes.io/serviceaccount/namespace": "kube-system",
+			},
+			namespace: "kube-system",
+		},
+		{
+			name: "oidc token",
+			claims: jwt.MapClaims{
+				"iss":   "https://accounts.example.com",
+				"sub":   "alice",
+				"email": "alice@example.com",
+			},
+		},
+		{
+			name: "unexpected claim type",
+			claims: jwt.MapClaims{
+				"kubernetes.io/serviceaccount/namespace": 42,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte("key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if namespace := NamespaceFromToken(tk); namespace != tt.namespace {
+				t.Errorf("expected namespace %q, got %q", tt.namespace, namespace)
+			}
+		})
+	}
+
+	if namespace := NamespaceFromToken("not-a-jwt"); namespace != "" {
+		t.Errorf("expected no namespace for an opaque token, got %q", namespace)
+	}
+}
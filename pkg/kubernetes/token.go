@@ -0,0 +1,151 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"sigs.k8s.io/yaml"
+)
+
+// TokenEnvVar is the environment variable holding the token when no other source is provided
+const TokenEnvVar = "KAL_TOKEN"
+
+// ServiceAccountFiles is the content of a service account token folder
+type ServiceAccountFiles struct {
+	Token string
+	// CAFile is the path of the `ca.crt` file, empty when the folder has none
+	CAFile    string
+	Namespace string
+}
+
+// ReadToken reads a token from a reader, trimming the surrounding white spaces
+func ReadToken(reader io.Reader) (string, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("empty token")
+	}
+
+	return token, nil
+}
+
+// ReadTokenFile reads a token from a file
+func ReadTokenFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return ReadToken(file)
+}
+
+// ReadServiceAccountDir reads the `token`, `ca.crt` and `namespace` files of a service account
+// token folder, using the layout of the folder mounted inside a POD
+//
+// Only the token file is required
+func ReadServiceAccountDir(dir string) (*ServiceAccountFiles, error) {
+	token, err := ReadTokenFile(filepath.Join(dir, "token"))
+	if err != nil {
+		return nil, err
+	}
+
+	saFiles := &ServiceAccountFiles{Token: token}
+
+	caFile := filepath.Join(dir, "ca.crt")
+	if _, err := os.Stat(caFile); err == nil {
+		saFiles.CAFile = caFile
+	}
+
+	if namespace, err := os.ReadFile(filepath.Join(dir, "namespace")); err == nil {
+		saFiles.Namespace = strings.TrimSpace(string(namespace))
+	}
+
+	return saFiles, nil
+}
+
+// LabeledToken is a token with the label naming it in the results
+type LabeledToken struct {
+	Label string `json:"label"`
+	Token string `json:"token"`
+}
+
+// UnmarshalJSON reads a LabeledToken from an object, or from a string holding only the token
+func (lt *LabeledToken) UnmarshalJSON(data []byte) error {
+	var token string
+	if err := json.Unmarshal(data, &token); err == nil {
+		lt.Token = token
+		return nil
+	}
+
+	type labeledToken LabeledToken
+	return json.Unmarshal(data, (*labeledToken)(lt))
+}
+
+// ReadTokensFile reads the tokens of a file
+//
+// The file is either a JSON or YAML list of tokens or labeled tokens, or it has one token per
+// line, ignoring empty lines and lines starting with `#`. Tokens without a label are named after
+// their JWT subject, or their position in the file
+func ReadTokensFile(path string) ([]*LabeledToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]*LabeledToken, 0)
+	if err := yaml.Unmarshal(data, &tokens); err != nil {
+		tokens = tokens[:0]
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			tokens = append(tokens, &LabeledToken{Token: line})
+		}
+	}
+
+	for i, token := range tokens {
+		token.Token = strings.TrimSpace(token.Token)
+		if token.Token == "" {
+			return nil, fmt.Errorf("empty token at position %d", i+1)
+		}
+
+		if token.Label == "" {
+			token.Label = TokenSubject(token.Token)
+		}
+
+		if token.Label == "" {
+			token.Label = fmt.Sprintf("token-%d", i+1)
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("no tokens found")
+	}
+
+	return tokens, nil
+}
+
+// TokenSubject returns the subject of a JWT token, without verifying it
+//
+// It returns an empty string when the token is not a JWT
+func TokenSubject(token string) string {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return ""
+	}
+
+	subject, _ := claims.GetSubject()
+	return subject
+}
@@ -0,0 +1,150 @@
+package kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadTokensFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		labels  []string
+		tokens  []string
+	}{
+		{
+			name:    "lines",
+			content: "# collected tokens\nfirst\n\n  second  \n",
+			labels:  []string{"token-1", "token-2"},
+			tokens:  []string{"first", "second"},
+		},
+		{
+			name:    "yaml",
+			content: "- label: ci\n  token: first\n- second\n",
+			labels:  []string{"ci", "token-2"},
+			tokens:  []string{"first", "second"},
+		},
+		{
+			name:    "json",
+			content: `[{"label": "ci", "token": "first"}, {"token": "second"}]`,
+			labels:  []string{"ci", "token-2"},
+			tokens:  []string{"first", "second"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			tokens, err := ReadTokensFile(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(tokens) != len(tt.tokens) {
+				t.Fatalf("expected %d tokens, got %d", len(tt.tokens), len(tokens))
+			}
+
+			for i, token := range tokens {
+				if token.Label != tt.labels[i] || token.Token != tt.tokens[i] {
+					t.Errorf("expected token %s (%s), got %s (%s)", tt.tokens[i], tt.labels[i], token.Token, token.Label)
+				}
+			}
+		})
+	}
+}
+
+func TestReadTokenFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		token   string
+		err     bool
+	}{
+		{"token", "abc.def.ghi", "abc.def.ghi", false},
+		{"white spaces", "\n  abc.def.ghi \t\n", "abc.def.ghi", false},
+		{"empty", " \n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := ReadTokenFile(path)
+			if (err != nil) != tt.err || token != tt.token {
+				t.Errorf("expected %q (error %t), got %q (%v)", tt.token, tt.err, token, err)
+			}
+		})
+	}
+
+	if _, err := ReadTokenFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestReadServiceAccountDir(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		namespace string
+		caFile    bool
+		err       bool
+	}{
+		{
+			name:      "token, namespace and ca",
+			files:     map[string]string{"token": "abc.def.ghi\n", "namespace": "apps\n", "ca.crt": "ca"},
+			namespace: "apps",
+			caFile:    true,
+		},
+		{
+			name:  "token only",
+			files: map[string]string{"token": "abc.def.ghi"},
+		},
+		{
+			name:  "without token",
+			files: map[string]string{"namespace": "apps", "ca.crt": "ca"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			saFiles, err := ReadServiceAccountDir(dir)
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if saFiles.Token != "abc.def.ghi" || saFiles.Namespace != tt.namespace {
+				t.Errorf("unexpected token %q and namespace %q", saFiles.Token, saFiles.Namespace)
+			}
+
+			caFile := ""
+			if tt.caFile {
+				caFile = filepath.Join(dir, "ca.crt")
+			}
+			if saFiles.CAFile != caFile {
+				t.Errorf("expected ca file %q, got %q", caFile, saFiles.CAFile)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package kubernetes
 
+import "k8s.io/apimachinery/pkg/runtime/schema"
+
 // ApiVerbs is the official list of API Verbs accepted by Kubernetes API
 var ApiVerbs = []string{
 	"create",
@@ -15,3 +17,51 @@ var ApiVerbs = []string{
 	"approve",
 	"escalate",
 }
+
+// SpecialVerbs maps the resources to the special API verbs that are only meaningful to them
+//
+// These verbs are evaluated by the authorizers but are not reported by the discovery API
+var SpecialVerbs = map[schema.GroupResource][]string{
+	{Group: "", Resource: "users"}:                                 {"impersonate"},
+	{Group: "", Resource: "groups"}:                                {"impersonate"},
+	{Group: "", Resource: "serviceaccounts"}:                       {"impersonate"},
+	{Group: "authentication.k8s.io", Resource: "uids"}:             {"impersonate"},
+	{Group: "rbac.authorization.k8s.io", Resource: "roles"}:        {"bind", "escalate"},
+	{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}: {"bind", "escalate"},
+	{Group: "certificates.k8s.io", Resource: "signers"}:            {"approve"},
+}
+
+// VirtualResources is the list of cluster-wide resources that are not served by the Kubernetes API,
+// but are evaluated by the authorizers for the special API verbs
+var VirtualResources = []schema.GroupVersionResource{
+	{Group: "", Version: "v1", Resource: "users"},
+	{Group: "", Version: "v1", Resource: "groups"},
+	{Group: "authentication.k8s.io", Version: "v1", Resource: "uids"},
+	{Group: "certificates.k8s.io", Version: "v1", Resource: "signers"},
+}
+
+// NonResourceVerbs is the list of API Verbs accepted by Kubernetes API for non-resource URLs
+var NonResourceVerbs = []string{
+	"get",
+	"post",
+	"put",
+	"patch",
+	"delete",
+}
+
+// SensitiveNonResourceURLs is the list of well-known non-resource URLs that expose sensitive information
+var SensitiveNonResourceURLs = []string{
+	"/metrics",
+	"/metrics/slis",
+	"/logs",
+	"/debug/pprof",
+	"/debug/flags/v",
+	"/healthz",
+	"/livez",
+	"/readyz",
+	"/version",
+	"/openapi/v2",
+	"/openapi/v3",
+	"/.well-known/openid-configuration",
+	"/openid/v1/jwks",
+}
@@ -0,0 +1,233 @@
+package policy
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Permission is the set of allowed verbs of a resource, sub-resource or non-resource URL in a namespace
+type Permission struct {
+	Group string
+	// Resource includes the sub-resource, like `pods/exec`
+	Resource       string
+	NonResourceURL string
+	// Namespace is empty for cluster-wide resources and non-resource URLs
+	Namespace string
+	Verbs     []string
+}
+
+// name returns the name of the permission resource, like `pods/exec`, `deployments.apps` or `/healthz`
+func (p *Permission) name() string {
+	if p.NonResourceURL != "" {
+		return p.NonResourceURL
+	}
+
+	return schema.GroupResource{Group: p.Group, Resource: p.Resource}.String()
+}
+
+// ViolationKind is how the allowed permissions differ from a policy
+type ViolationKind string
+
+const (
+	// Exceeded verbs are allowed but not declared in the policy
+	Exceeded ViolationKind = "exceeded"
+	// Missing verbs are declared in the policy but not allowed
+	Missing ViolationKind = "missing"
+	// UnknownIdentity targets do not match any identity of the policy
+	UnknownIdentity ViolationKind = "unknown-identity"
+	// NotAnalyzed targets could not be analyzed, so their permissions are unknown
+	NotAnalyzed ViolationKind = "not-analyzed"
+)
+
+// Violation is a difference between the allowed permissions of a target and its policy
+type Violation struct {
+	Kind ViolationKind `json:"kind"`
+	// Resource is like `pods/exec`, `deployments.apps` or `/healthz`, as globs for missing verbs
+	Resource string `json:"resource,omitempty"`
+	// APIGroups are the groups expected for a missing Resource not found in any of them
+	APIGroups []string `json:"apiGroups,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	Verbs     []string `json:"verbs,omitempty"`
+}
+
+// Resource is a resource found in the cluster, with its sub-resource, like `pods/exec`
+type Resource struct {
+	Group    string
+	Resource string
+}
+
+// Check returns the violations of the allowed permissions of a target, identified by its names
+//
+// Allowed verbs not matched by a rule of the identities are exceeded. With checkMissing, the verbs
+// of the rules are expected too, in every namespace of the rules and in every api group where the
+// resource was found. A resource not found in any group of its rule is expected in one of them,
+// and a glob is satisfied by a single allowed match
+func (p *Policy) Check(names []string, permissions []*Permission, resources []Resource, checkMissing bool) []*Violation {
+	rules, found := p.Rules(names...)
+	if !found {
+		return []*Violation{{Kind: UnknownIdentity}}
+	}
+
+	violations := make([]*Violation, 0)
+	for _, permission := range permissions {
+		exceeded := make([]string, 0)
+		for _, verb := range permission.Verbs {
+			if !matchRules(rules, permission, verb) {
+				exceeded = append(exceeded, verb)
+			}
+		}
+
+		if len(exceeded) > 0 {
+			violations = append(violations, &Violation{
+				Kind:      Exceeded,
+				Resource:  permission.name(),
+				Namespace: permission.Namespace,
+				Verbs:     exceeded,
+			})
+		}
+	}
+
+	if checkMissing {
+		for _, rule := range rules {
+			violations = append(violations, missingVerbs(rule, permissions, resources)...)
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Namespace < b.Namespace
+	})
+
+	return violations
+}
+
+// matchRules checks if one of the rules allows a verb of a permission
+func matchRules(rules []*Rule, permission *Permission, verb string) bool {
+	for _, rule := range rules {
+		if rule.Matches(permission, verb) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expectation is a resource or non-resource URL expected by a rule, in one of its api groups
+type expectation struct {
+	groups         []string
+	resource       string
+	nonResourceURL string
+	namespace      string
+}
+
+// violation returns the missing verbs of an expectation
+func (e *expectation) violation(verbs []string) *Violation {
+	v := &Violation{Kind: Missing, Resource: e.nonResourceURL, Namespace: e.namespace, Verbs: verbs}
+
+	switch {
+	case e.nonResourceURL != "":
+	case len(e.groups) == 1:
+		v.Resource = schema.GroupResource{Group: e.groups[0], Resource: e.resource}.String()
+	default:
+		v.Resource = e.resource
+		v.APIGroups = e.groups
+	}
+
+	return v
+}
+
+// missingVerbs returns the verbs of a rule not allowed, per resource and namespace of the rule
+//
+// The group and resource pairs of the rule that were not found in the cluster are not expected,
+// like `pods` in the `apps` group of a rule with the core and `apps` groups
+func missingVerbs(rule *Rule, permissions []*Permission, resources []Resource) []*Violation {
+	expected := make([]*expectation, 0)
+	for _, url := range rule.NonResourceURLs {
+		expected = append(expected, &expectation{nonResourceURL: url})
+	}
+
+	namespaces := rule.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, resource := range rule.Resources {
+		groups := make([]string, 0, len(rule.APIGroups))
+		for _, group := range rule.APIGroups {
+			if found(group, resource, resources) {
+				groups = append(groups, group)
+			}
+		}
+
+		for _, namespace := range namespaces {
+			if len(groups) == 0 {
+				expected = append(expected, &expectation{groups: rule.APIGroups, resource: resource, namespace: namespace})
+				continue
+			}
+
+			for _, group := range groups {
+				expected = append(expected, &expectation{groups: []string{group}, resource: resource, namespace: namespace})
+			}
+		}
+	}
+
+	violations := make([]*Violation, 0)
+	for _, e := range expected {
+		missing := make([]string, 0)
+		for _, verb := range rule.Verbs {
+			if !allowed(e, verb, permissions) {
+				missing = append(missing, verb)
+			}
+		}
+
+		if len(missing) > 0 {
+			violations = append(violations, e.violation(missing))
+		}
+	}
+
+	return violations
+}
+
+// found checks if a resource of the cluster matches the group and resource globs
+func found(group, resource string, resources []Resource) bool {
+	for _, r := range resources {
+		if match(group, r.Group) && match(resource, r.Resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed checks if a permission matches the globs of an expectation, in one of its groups, with
+// an allowed verb matching the verb glob. An expectation without namespace matches any namespace
+func allowed(e *expectation, verb string, permissions []*Permission) bool {
+	for _, permission := range permissions {
+		if e.nonResourceURL != "" {
+			if permission.NonResourceURL == "" || !match(e.nonResourceURL, permission.NonResourceURL) {
+				continue
+			}
+		} else {
+			if permission.NonResourceURL != "" ||
+				!matchAny(e.groups, permission.Group) ||
+				!match(e.resource, permission.Resource) ||
+				(e.namespace != "" && (permission.Namespace == "" || !match(e.namespace, permission.Namespace))) {
+				continue
+			}
+		}
+
+		for _, allowedVerb := range permission.Verbs {
+			if match(verb, allowedVerb) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
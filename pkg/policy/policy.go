@@ -0,0 +1,159 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Rule declares expected verbs of resources or non-resource URLs, like an RBAC rule
+//
+// Every field accepts `*` globs, like `*.cert-manager.io` or `pods/*`. Resources include their
+// sub-resource, like `pods/exec`, and the core group is the empty string. Without namespaces,
+// the rule matches every namespace and the cluster-wide resources
+type Rule struct {
+	APIGroups       []string `json:"apiGroups,omitempty"`
+	Resources       []string `json:"resources,omitempty"`
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty"`
+	Namespaces      []string `json:"namespaces,omitempty"`
+	Verbs           []string `json:"verbs"`
+}
+
+// Validate checks the rule has verbs and resources or non-resource URLs, with their api groups
+func (r *Rule) Validate() error {
+	switch {
+	case len(r.Verbs) == 0:
+		return fmt.Errorf("rule without verbs")
+	case len(r.Resources) == 0 && len(r.NonResourceURLs) == 0:
+		return fmt.Errorf("rule without resources or non-resource urls")
+	case len(r.Resources) > 0 && len(r.APIGroups) == 0:
+		return fmt.Errorf("rule with resources %v without api groups", r.Resources)
+	case len(r.NonResourceURLs) > 0 && len(r.Namespaces) > 0:
+		return fmt.Errorf("rule with non-resource urls %v cannot have namespaces", r.NonResourceURLs)
+	}
+
+	return nil
+}
+
+// Matches checks if the rule allows a verb of a permission
+func (r *Rule) Matches(permission *Permission, verb string) bool {
+	if !matchAny(r.Verbs, verb) {
+		return false
+	}
+
+	if permission.NonResourceURL != "" {
+		return matchAny(r.NonResourceURLs, permission.NonResourceURL)
+	}
+
+	if len(r.Namespaces) > 0 && (permission.Namespace == "" || !matchAny(r.Namespaces, permission.Namespace)) {
+		return false
+	}
+
+	return matchAny(r.APIGroups, permission.Group) && matchAny(r.Resources, permission.Resource)
+}
+
+// Identity declares the permissions expected for the targets matching its name
+type Identity struct {
+	// Name is a glob matched against the username, the label and the kubeconfig context of a target
+	Name  string  `json:"identity"`
+	Rules []*Rule `json:"permissions"`
+}
+
+// Policy declares, per identity, the permissions a target is allowed to have
+type Policy struct {
+	Identities []*Identity `json:"identities"`
+}
+
+// Validate checks every identity has a name and valid rules
+func (p *Policy) Validate() error {
+	if len(p.Identities) == 0 {
+		return fmt.Errorf("policy without identities")
+	}
+
+	for _, identity := range p.Identities {
+		if identity.Name == "" {
+			return fmt.Errorf("identity without name")
+		}
+
+		for _, rule := range identity.Rules {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("identity [%s]: %w", identity.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Rules returns the rules of every identity matching one of the names of a target
+func (p *Policy) Rules(names ...string) ([]*Rule, bool) {
+	rules := make([]*Rule, 0)
+	found := false
+
+	for _, identity := range p.Identities {
+		for _, name := range names {
+			if name != "" && match(identity.Name, name) {
+				rules = append(rules, identity.Rules...)
+				found = true
+				break
+			}
+		}
+	}
+
+	return rules, found
+}
+
+// ReadFile reads a policy from a YAML or JSON file, with an `identities` list
+func ReadFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("could not parse policy file: %w", err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// matchAny checks if a value matches one of the globs
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match checks if a value matches a glob, where `*` matches any sequence of characters, `/` included
+func match(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{"pods", "pods", true},
+		{"pods", "pods/exec", false},
+		{"pods*", "pods/exec", true},
+		{"*.cert-manager.io", "acme.cert-manager.io", true},
+		{"*.cert-manager.io", "cert-manager.io", false},
+		{"system:serviceaccount:*:deployer", "system:serviceaccount:ci:deployer", true},
+		{"a*b*c", "abc", true},
+		{"a*a", "a", false},
+		{"*", "", true},
+	}
+
+	for _, tt := range tests {
+		if match(tt.pattern, tt.value) != tt.expected {
+			t.Errorf("expected match(%q, %q) to be %v", tt.pattern, tt.value, tt.expected)
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := &Policy{Identities: []*Identity{
+		{
+			Name: "system:serviceaccount:ci:*",
+			Rules: []*Rule{
+				{APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments", "statefulsets"}, Namespaces: []string{"ci"}, Verbs: []string{"get", "list"}},
+				{APIGroups: []string{""}, Resources: []string{"pods/*"}, Namespaces: []string{"ci"}, Verbs: []string{"create"}},
+				{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+			},
+		},
+	}}
+
+	permissions := []*Permission{
+		{Resource: "pods", Namespace: "ci", Verbs: []string{"get", "list", "delete"}},
+		{Resource: "pods/exec", Namespace: "ci", Verbs: []string{"create"}},
+		{Resource: "secrets", Namespace: "kube-system", Verbs: []string{"list"}},
+		{Group: "apps", Resource: "deployments", Namespace: "ci", Verbs: []string{"get"}},
+		{NonResourceURL: "/healthz", Verbs: []string{"get"}},
+	}
+
+	resources := []Resource{
+		{Resource: "pods"},
+		{Resource: "pods/exec"},
+		{Resource: "secrets"},
+		{Group: "apps", Resource: "deployments"},
+	}
+
+	violations := p.Check([]string{"", "system:serviceaccount:ci:deployer"}, permissions, resources, true)
+
+	// the pairs not found in the cluster, like pods.apps or core deployments, are not expected
+	expected := []*Violation{
+		{Kind: Exceeded, Resource: "pods", Namespace: "ci", Verbs: []string{"delete"}},
+		{Kind: Exceeded, Resource: "secrets", Namespace: "kube-system", Verbs: []string{"list"}},
+		{Kind: Missing, Resource: "deployments.apps", Namespace: "ci", Verbs: []string{"list"}},
+		{Kind: Missing, Resource: "statefulsets", APIGroups: []string{"", "apps"}, Namespace: "ci", Verbs: []string{"get", "list"}},
+	}
+	if !reflect.DeepEqual(violations, expected) {
+		for _, v := range violations {
+			t.Logf("%+v", v)
+		}
+		t.Errorf("unexpected violations")
+	}
+
+	permissions = append(permissions,
+		&Permission{Group: "apps", Resource: "deployments", Namespace: "ci", Verbs: []string{"list"}},
+		&Permission{Group: "apps", Resource: "statefulsets", Namespace: "ci", Verbs: []string{"get", "list"}},
+	)
+	resources = append(resources, Resource{Group: "apps", Resource: "statefulsets"})
+	if violations := p.Check([]string{"system:serviceaccount:ci:deployer"}, permissions, resources, true); len(violations) != 2 || violations[1].Kind != Exceeded {
+		t.Errorf("expected only the exceeded verbs, got %+v", violations)
+	}
+
+	if violations := p.Check([]string{"admin"}, permissions, resources, false); len(violations) != 1 || violations[0].Kind != UnknownIdentity {
+		t.Errorf("expected an unknown identity, got %v", violations)
+	}
+}
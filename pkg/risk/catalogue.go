@@ -0,0 +1,162 @@
+package risk
+
+// BuiltinRules is the catalogue of permissions that allow to escalate privileges or to read
+// sensitive data, evaluated by every Engine
+var BuiltinRules = []*Rule{
+	{
+		ID:          "secrets-read-cluster",
+		Title:       "Read secrets in every namespace",
+		Description: "Service account tokens, credentials and certificates of the whole cluster can be read",
+		Severity:    Critical,
+		APIGroups:   []string{""},
+		Resources:   []string{"secrets"},
+		Verbs:       []string{"get", "list", "watch"},
+		ClusterWide: true,
+	},
+	{
+		ID:          "secrets-read",
+		Title:       "Read secrets",
+		Description: "Service account tokens and credentials stored in the namespace can be read",
+		Severity:    High,
+		APIGroups:   []string{""},
+		Resources:   []string{"secrets"},
+		Verbs:       []string{"get", "list", "watch"},
+	},
+	{
+		ID:          "pod-creation",
+		Title:       "Create pods",
+		Description: "A privileged or host path pod escapes to the node, and any service account of the namespace can be mounted",
+		Severity:    High,
+		APIGroups:   []string{""},
+		Resources:   []string{"pods"},
+		Verbs:       []string{"create"},
+	},
+	{
+		ID:          "workload-creation",
+		Title:       "Create or modify workloads",
+		Description: "Workload controllers create pods on behalf of the identity, with the same node escape as pod creation",
+		Severity:    High,
+		APIGroups:   []string{"", "apps", "batch"},
+		Resources:   []string{"replicationcontrollers", "deployments", "daemonsets", "statefulsets", "replicasets", "jobs", "cronjobs"},
+		Verbs:       []string{"create", "update", "patch"},
+	},
+	{
+		ID:          "pod-exec",
+		Title:       "Execute commands in pods",
+		Description: "Commands run in existing containers, with their service account tokens and mounted secrets",
+		Severity:    High,
+		APIGroups:   []string{""},
+		Resources:   []string{"pods/exec", "pods/attach"},
+		Verbs:       []string{"create", "get"},
+	},
+	{
+		ID:          "pod-ephemeral-containers",
+		Title:       "Add ephemeral containers to pods",
+		Description: "A debug container is added to a running pod, sharing its namespaces and service account",
+		Severity:    High,
+		APIGroups:   []string{""},
+		Resources:   []string{"pods/ephemeralcontainers"},
+		Verbs:       []string{"update", "patch"},
+	},
+	{
+		ID:          "rbac-escalate-bind",
+		Title:       "Escalate or bind roles",
+		Description: "Roles can be granted more permissions than the identity has, or bound to any subject",
+		Severity:    Critical,
+		APIGroups:   []string{"rbac.authorization.k8s.io"},
+		Resources:   []string{"roles", "clusterroles"},
+		Verbs:       []string{"escalate", "bind"},
+	},
+	{
+		ID:          "rbac-bindings",
+		Title:       "Create or modify role bindings",
+		Description: "The roles the identity is allowed to bind can be granted to any subject",
+		Severity:    High,
+		APIGroups:   []string{"rbac.authorization.k8s.io"},
+		Resources:   []string{"rolebindings", "clusterrolebindings"},
+		Verbs:       []string{"create", "update", "patch"},
+	},
+	{
+		ID:          "impersonation",
+		Title:       "Impersonate users, groups or service accounts",
+		Description: "Requests are sent as another identity, like a member of the system:masters group",
+		Severity:    Critical,
+		APIGroups:   []string{"", "authentication.k8s.io"},
+		Resources:   []string{"users", "groups", "serviceaccounts", "uids"},
+		Verbs:       []string{"impersonate"},
+	},
+	{
+		ID:          "nodes-proxy",
+		Title:       "Proxy requests to the kubelet API",
+		Description: "The kubelet API of the nodes runs commands in any pod, bypassing the admission and audit of the Kubernetes API",
+		Severity:    Critical,
+		APIGroups:   []string{""},
+		Resources:   []string{"nodes/proxy"},
+		Verbs:       []string{"get", "create"},
+	},
+	{
+		ID:          "serviceaccount-token",
+		Title:       "Create service account tokens",
+		Description: "Tokens are issued for the service accounts of the namespace, authenticating as them",
+		Severity:    High,
+		APIGroups:   []string{""},
+		Resources:   []string{"serviceaccounts/token"},
+		Verbs:       []string{"create"},
+	},
+	{
+		ID:          "admission-webhooks",
+		Title:       "Create or modify admission webhooks",
+		Description: "Every request to the Kubernetes API, including secrets and pods, is sent to or can be blocked by a webhook",
+		Severity:    High,
+		APIGroups:   []string{"admissionregistration.k8s.io"},
+		Resources:   []string{"mutatingwebhookconfigurations", "validatingwebhookconfigurations"},
+		Verbs:       []string{"create", "update", "patch"},
+	},
+	{
+		ID:          "csr-approval",
+		Title:       "Approve certificate signing requests",
+		Description: "Client certificates are issued for any user or group, like system:masters",
+		Severity:    Critical,
+		APIGroups:   []string{"certificates.k8s.io"},
+		Resources:   []string{"certificatesigningrequests/approval"},
+		Verbs:       []string{"update", "patch"},
+		Requires: []*Requirement{
+			{APIGroups: []string{"certificates.k8s.io"}, Resources: []string{"signers"}, Verbs: []string{"approve"}},
+		},
+	},
+	{
+		ID:          "persistent-volume-creation",
+		Title:       "Create persistent volumes",
+		Description: "A host path persistent volume mounts the file system of a node in a pod",
+		Severity:    Medium,
+		APIGroups:   []string{""},
+		Resources:   []string{"persistentvolumes"},
+		Verbs:       []string{"create"},
+	},
+	{
+		ID:          "node-modification",
+		Title:       "Modify nodes",
+		Description: "Labels and taints of the nodes are changed, attracting sensitive pods to a compromised node",
+		Severity:    Medium,
+		APIGroups:   []string{""},
+		Resources:   []string{"nodes"},
+		Verbs:       []string{"update", "patch"},
+	},
+	{
+		ID:          "pod-port-forward",
+		Title:       "Forward ports of pods",
+		Description: "Services listening in the pods are reached, bypassing the network policies",
+		Severity:    Medium,
+		APIGroups:   []string{""},
+		Resources:   []string{"pods/portforward"},
+		Verbs:       []string{"create", "get"},
+	},
+	{
+		ID:              "debug-endpoints",
+		Title:           "Read debug endpoints of the API server",
+		Description:     "Profiling and log endpoints disclose information about the API server",
+		Severity:        Low,
+		NonResourceURLs: []string{"/debug/*", "/logs", "/logs/*"},
+		Verbs:           []string{"get"},
+	},
+}
@@ -0,0 +1,57 @@
+package risk
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// nodeColors are the DOT colors of the node kinds
+var nodeColors = map[NodeKind]string{
+	IdentityNode:        "black",
+	ServiceAccountsNode: "orange",
+	NamespaceAdminNode:  "orangered",
+	HostNode:            "red",
+	ClusterAdminNode:    "red",
+}
+
+// WriteDOT writes the graph as a Graphviz DOT digraph with a name
+//
+// The edges are labelled with their description and the permissions they use
+func (g *Graph) WriteDOT(writer io.Writer, name string) error {
+	builder := &strings.Builder{}
+
+	fmt.Fprintf(builder, "digraph %s {\n", dotQuote(name))
+	builder.WriteString("  rankdir=LR;\n")
+	builder.WriteString("  node [shape=box];\n")
+
+	for _, n := range g.Nodes {
+		shape := "box"
+		if n.Kind == IdentityNode {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(builder, "  %s [label=%s, shape=%s, color=%s];\n", dotQuote(n.ID), dotQuote(n.Label), shape, nodeColors[n.Kind])
+	}
+
+	for _, e := range g.Edges {
+		label := e.Description
+		if len(e.Permissions) > 0 {
+			label += "\n" + strings.Join(e.Permissions, "\n")
+		}
+		fmt.Fprintf(builder, "  %s -> %s [label=%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(label))
+	}
+
+	builder.WriteString("}\n")
+
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// dotQuote returns a DOT quoted string, with the line breaks as DOT escapes
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+
+	return `"` + s + `"`
+}
@@ -0,0 +1,204 @@
+package risk
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Permission holds the allowed verbs of a resource, sub-resource or non-resource URL in a namespace
+//
+// Cluster-wide resources and non-resource URLs have an empty Namespace
+type Permission struct {
+	Group          string
+	Resource       string
+	SubResource    string
+	NonResourceURL string
+	Namespace      string
+	Verbs          []string
+}
+
+// resource returns the resource, with the sub-resource appended, like `pods/exec`
+func (p *Permission) resource() string {
+	if p.SubResource != "" {
+		return p.Resource + "/" + p.SubResource
+	}
+
+	return p.Resource
+}
+
+// verbString returns the readable representation of a verb of the permission, like `create pods/exec`
+func (p *Permission) verbString(verb string) string {
+	if p.NonResourceURL != "" {
+		return verb + " " + p.NonResourceURL
+	}
+
+	return verb + " " + schema.GroupResource{Group: p.Group, Resource: p.resource()}.String()
+}
+
+// Scope is the namespaces where the permissions were analyzed
+type Scope struct {
+	Namespaces []string
+	// AllNamespaces is set when Namespaces are every namespace of the cluster
+	AllNamespaces bool
+}
+
+// Finding is a Rule matched by the permissions of an authentication
+type Finding struct {
+	RuleID      string   `json:"ruleID"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Severity    Severity `json:"severity"`
+	// Permissions are the matched verbs and resources, like `create pods/exec`
+	Permissions []string `json:"permissions"`
+	// Namespaces are the namespaces of the matched namespaced permissions
+	Namespaces []string `json:"namespaces,omitempty"`
+	// ClusterWide is set when a matched permission is not restricted to a namespace
+	ClusterWide bool `json:"clusterWide"`
+}
+
+// Engine evaluates permissions against a catalogue of rules
+type Engine struct {
+	rules []*Rule
+}
+
+// NewEngine creates an Engine with the built-in rules, extended by custom rules
+//
+// A custom rule replaces the built-in rule with the same id
+func NewEngine(custom ...*Rule) *Engine {
+	rules := make([]*Rule, 0, len(BuiltinRules)+len(custom))
+	positions := make(map[string]int)
+
+	for _, rule := range append(append([]*Rule{}, BuiltinRules...), custom...) {
+		if position, ok := positions[rule.ID]; ok {
+			rules[position] = rule
+			continue
+		}
+
+		positions[rule.ID] = len(rules)
+		rules = append(rules, rule)
+	}
+
+	return &Engine{rules: rules}
+}
+
+// Rules returns the rules of the engine
+func (e *Engine) Rules() []*Rule {
+	return e.rules
+}
+
+// Evaluate returns the findings of the rules matched by the permissions, the most severe first
+func (e *Engine) Evaluate(permissions []*Permission, scope Scope) []*Finding {
+	findings := make([]*Finding, 0)
+
+	for _, rule := range e.rules {
+		if finding := evaluateRule(rule, permissions, scope); finding != nil {
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity.Rank() != findings[j].Severity.Rank() {
+			return findings[i].Severity.Rank() > findings[j].Severity.Rank()
+		}
+		return findings[i].RuleID < findings[j].RuleID
+	})
+
+	return findings
+}
+
+// evaluateRule returns the finding of a rule, or nil when no permission matches it
+func evaluateRule(rule *Rule, permissions []*Permission, scope Scope) *Finding {
+	matched := make(map[string]struct{})
+	namespaces := make(map[string]struct{})
+	clusterWide := false
+
+	// namespaced permissions of cluster-wide rules must be allowed in every namespace
+	allowedNamespaces := make(map[string]map[string]struct{})
+
+	for _, permission := range permissions {
+		for _, verb := range permission.Verbs {
+			if !rule.Matches(permission, verb) {
+				continue
+			}
+
+			verbString := permission.verbString(verb)
+
+			switch {
+			case permission.Namespace == "":
+				matched[verbString] = struct{}{}
+				clusterWide = true
+			case rule.ClusterWide:
+				if _, ok := allowedNamespaces[verbString]; !ok {
+					allowedNamespaces[verbString] = make(map[string]struct{})
+				}
+				allowedNamespaces[verbString][permission.Namespace] = struct{}{}
+			default:
+				matched[verbString] = struct{}{}
+				namespaces[permission.Namespace] = struct{}{}
+			}
+		}
+	}
+
+	for verbString, allowed := range allowedNamespaces {
+		if scope.AllNamespaces && coversAll(allowed, scope.Namespaces) {
+			matched[verbString] = struct{}{}
+			clusterWide = true
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil
+	}
+
+	for _, req := range rule.Requires {
+		satisfied := false
+		for _, permission := range permissions {
+			for _, verb := range permission.Verbs {
+				if req.matches(permission, verb) {
+					matched[permission.verbString(verb)] = struct{}{}
+					satisfied = true
+				}
+			}
+		}
+
+		if !satisfied {
+			return nil
+		}
+	}
+
+	return &Finding{
+		RuleID:      rule.ID,
+		Title:       rule.Title,
+		Description: rule.Description,
+		Severity:    rule.Severity,
+		Permissions: sortedKeys(matched),
+		Namespaces:  sortedKeys(namespaces),
+		ClusterWide: clusterWide,
+	}
+}
+
+// coversAll checks if every namespace is in the set
+func coversAll(set map[string]struct{}, namespaces []string) bool {
+	if len(namespaces) == 0 {
+		return false
+	}
+
+	for _, ns := range namespaces {
+		if _, ok := set[ns]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
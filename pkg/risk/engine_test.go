@@ -0,0 +1,200 @@
+package risk
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEngineEvaluate(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []*Permission
+		scope       Scope
+		findings    []string
+	}{
+		{
+			name: "namespaced secrets",
+			permissions: []*Permission{
+				{Resource: "secrets", Namespace: "default", Verbs: []string{"get", "list"}},
+				{Resource: "configmaps", Namespace: "default", Verbs: []string{"get"}},
+			},
+			scope:    Scope{Namespaces: []string{"default", "apps"}, AllNamespaces: true},
+			findings: []string{"secrets-read"},
+		},
+		{
+			name: "secrets in every namespace",
+			permissions: []*Permission{
+				{Resource: "secrets", Namespace: "default", Verbs: []string{"list"}},
+				{Resource: "secrets", Namespace: "apps", Verbs: []string{"list"}},
+			},
+			scope:    Scope{Namespaces: []string{"default", "apps"}, AllNamespaces: true},
+			findings: []string{"secrets-read-cluster", "secrets-read"},
+		},
+		{
+			name: "secrets in the provided namespaces",
+			permissions: []*Permission{
+				{Resource: "secrets", Namespace: "default", Verbs: []string{"list"}},
+			},
+			scope:    Scope{Namespaces: []string{"default"}},
+			findings: []string{"secrets-read"},
+		},
+		{
+			name: "severity order",
+			permissions: []*Permission{
+				{Resource: "pods", Namespace: "default", Verbs: []string{"create"}},
+				{Resource: "nodes", SubResource: "proxy", Verbs: []string{"get"}},
+				{NonResourceURL: "/debug/pprof", Verbs: []string{"get"}},
+				{Group: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations", Verbs: []string{"patch"}},
+			},
+			scope:    Scope{Namespaces: []string{"default"}},
+			findings: []string{"nodes-proxy", "admission-webhooks", "pod-creation", "debug-endpoints"},
+		},
+		{
+			name: "csr approval without signer approval",
+			permissions: []*Permission{
+				{Group: "certificates.k8s.io", Resource: "certificatesigningrequests", SubResource: "approval", Verbs: []string{"update"}},
+			},
+			scope:    Scope{Namespaces: []string{"default"}},
+			findings: []string{},
+		},
+		{
+			name: "signer approval without csr approval",
+			permissions: []*Permission{
+				{Group: "certificates.k8s.io", Resource: "signers", Verbs: []string{"approve"}},
+			},
+			scope:    Scope{Namespaces: []string{"default"}},
+			findings: []string{},
+		},
+		{
+			name: "csr and signer approval",
+			permissions: []*Permission{
+				{Group: "certificates.k8s.io", Resource: "certificatesigningrequests", SubResource: "approval", Verbs: []string{"update"}},
+				{Group: "certificates.k8s.io", Resource: "signers", Verbs: []string{"approve"}},
+			},
+			scope:    Scope{Namespaces: []string{"default"}},
+			findings: []string{"csr-approval"},
+		},
+		{
+			name: "harmless permissions",
+			permissions: []*Permission{
+				{Resource: "pods", Namespace: "default", Verbs: []string{"get", "list"}},
+				{NonResourceURL: "/healthz", Verbs: []string{"get"}},
+			},
+			scope:    Scope{Namespaces: []string{"default"}},
+			findings: []string{},
+		},
+	}
+
+	engine := NewEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := make([]string, 0)
+			for _, finding := range engine.Evaluate(tt.permissions, tt.scope) {
+				ids = append(ids, finding.RuleID)
+			}
+
+			if !reflect.DeepEqual(ids, tt.findings) {
+				t.Errorf("expected findings %v, got %v", tt.findings, ids)
+			}
+		})
+	}
+}
+
+func TestReadRulesFile(t *testing.T) {
+	content := `rules:
+- id: pod-creation
+  title: Create pods
+  severity: critical
+  apiGroups: [""]
+  resources: [pods]
+  verbs: [create]
+- id: configmaps-read
+  title: Read configmaps
+  severity: low
+  apiGroups: [""]
+  resources: [configmaps]
+  verbs: [get, list]
+`
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ReadRulesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	engine := NewEngine(rules...)
+	if len(engine.Rules()) != len(BuiltinRules)+1 {
+		t.Errorf("expected the custom rule to replace the built-in one, got %d rules", len(engine.Rules()))
+	}
+
+	findings := engine.Evaluate([]*Permission{
+		{Resource: "pods", Namespace: "default", Verbs: []string{"create"}},
+		{Resource: "configmaps", Namespace: "default", Verbs: []string{"list"}},
+	}, Scope{Namespaces: []string{"default"}})
+
+	if len(findings) != 2 || findings[0].Severity != Critical || findings[1].RuleID != "configmaps-read" {
+		t.Errorf("unexpected findings %+v", findings)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("rules:\n- id: no-severity\n  title: x\n  verbs: [get]\n  resources: [pods]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadRulesFile(invalid); err == nil {
+		t.Error("expected an error for a rule without severity")
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  *Rule
+		valid bool
+	}{
+		{
+			name:  "resources",
+			rule:  &Rule{ID: "pods", Title: "Pods", Severity: Low, APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			valid: true,
+		},
+		{
+			name:  "non-resource urls",
+			rule:  &Rule{ID: "metrics", Title: "Metrics", Severity: Low, NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+			valid: true,
+		},
+		{
+			name: "resources without api groups",
+			rule: &Rule{ID: "pods", Title: "Pods", Severity: Low, Resources: []string{"pods"}, Verbs: []string{"get"}},
+		},
+		{
+			name: "requirement without api groups",
+			rule: &Rule{
+				ID: "pods", Title: "Pods", Severity: Low, APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"},
+				Requires: []*Requirement{{Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+			},
+		},
+		{
+			name: "unknown severity",
+			rule: &Rule{ID: "pods", Title: "Pods", Severity: "urgent", APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %t, got error %v", tt.valid, err)
+			}
+		})
+	}
+
+	for _, rule := range BuiltinRules {
+		if err := rule.Validate(); err != nil {
+			t.Errorf("invalid built-in rule: %s", err)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package risk
+
+import (
+	"sort"
+	"strings"
+)
+
+// NodeKind is the kind of privilege represented by a Node of an escalation Graph
+type NodeKind string
+
+const (
+	// IdentityNode is the analyzed identity, where every path starts
+	IdentityNode NodeKind = "identity"
+	// ServiceAccountsNode is the permissions of the service accounts of a namespace
+	ServiceAccountsNode NodeKind = "service-accounts"
+	// NamespaceAdminNode is the admin cluster role in a namespace
+	NamespaceAdminNode NodeKind = "namespace-admin"
+	// HostNode is root access to a node of the cluster
+	HostNode NodeKind = "host"
+	// ClusterAdminNode is full access to the cluster
+	ClusterAdminNode NodeKind = "cluster-admin"
+)
+
+// rank returns the order of the node kinds in the paths, the most privileged first
+func (k NodeKind) rank() int {
+	switch k {
+	case ClusterAdminNode:
+		return 4
+	case HostNode:
+		return 3
+	case NamespaceAdminNode:
+		return 2
+	case ServiceAccountsNode:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Node is a privilege reachable by the identity
+type Node struct {
+	ID        string   `json:"id"`
+	Kind      NodeKind `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	Label     string   `json:"label"`
+}
+
+// Edge is an escalation step from a privilege to another, using the listed permissions
+type Edge struct {
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Path is the shortest sequence of steps from the identity to a privilege
+type Path struct {
+	Target string  `json:"target"`
+	Label  string  `json:"label"`
+	Steps  []*Edge `json:"steps"`
+}
+
+// Graph holds the escalation steps allowed by the permissions of an identity
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+	Paths []*Path `json:"paths"`
+}
+
+// requirement is satisfied when any verb is allowed in any resource of the group
+//
+// ClusterScoped requirements are checked on cluster-wide resources, the others in a namespace.
+// AnyScope requirements are satisfied by the namespace or by the cluster-wide resources, like the
+// cluster roles referenced by the role bindings of a namespace
+type requirement struct {
+	Verbs         []string
+	Group         string
+	Resources     []string
+	ClusterScoped bool
+	AnyScope      bool
+}
+
+// workloadRequirements are satisfied by the creation of pods or of the controllers creating pods
+var workloadRequirements = []requirement{
+	{Verbs: []string{"create"}, Group: "", Resources: []string{"pods", "replicationcontrollers"}},
+	{Verbs: []string{"create"}, Group: "apps", Resources: []string{"deployments", "daemonsets", "statefulsets", "replicasets"}},
+	{Verbs: []string{"create"}, Group: "batch", Resources: []string{"jobs", "cronjobs"}},
+}
+
+// graphBuilder adds the nodes and edges of a Graph from the allowed permissions
+type graphBuilder struct {
+	permissions []*Permission
+	graph       *Graph
+	nodes       map[string]*Node
+	edges       map[[2]string]*Edge
+}
+
+// BuildEscalationGraph returns the escalation steps allowed by the permissions, chained into
+// the shortest path from the identity to every reachable privilege
+//
+// The steps are based on the permissions only: the pod security admission, the existing service
+// accounts and the permissions they hold are not known
+func BuildEscalationGraph(permissions []*Permission, scope Scope) *Graph {
+	b := &graphBuilder{
+		permissions: permissions,
+		graph:       &Graph{Nodes: make([]*Node, 0), Edges: make([]*Edge, 0), Paths: make([]*Path, 0)},
+		nodes:       make(map[string]*Node),
+		edges:       make(map[[2]string]*Edge),
+	}
+
+	identity := &Node{ID: string(IdentityNode), Kind: IdentityNode, Label: "identity"}
+	clusterAdmin := &Node{ID: string(ClusterAdminNode), Kind: ClusterAdminNode, Label: "cluster admin"}
+	host := &Node{ID: string(HostNode), Kind: HostNode, Label: "node host"}
+
+	b.edge(identity, clusterAdmin, "", "impersonate a member of the system:masters group",
+		requirement{Verbs: []string{"impersonate"}, Resources: []string{"users", "groups"}, ClusterScoped: true})
+	b.edge(identity, clusterAdmin, "", "bind the cluster-admin cluster role to the identity",
+		requirement{Verbs: []string{"create", "update", "patch"}, Group: "rbac.authorization.k8s.io", Resources: []string{"clusterrolebindings"}, ClusterScoped: true},
+		requirement{Verbs: []string{"bind"}, Group: "rbac.authorization.k8s.io", Resources: []string{"clusterroles"}, ClusterScoped: true})
+	b.edge(identity, clusterAdmin, "", "add every permission to a cluster role",
+		requirement{Verbs: []string{"update", "patch"}, Group: "rbac.authorization.k8s.io", Resources: []string{"clusterroles"}, ClusterScoped: true},
+		requirement{Verbs: []string{"escalate"}, Group: "rbac.authorization.k8s.io", Resources: []string{"clusterroles"}, ClusterScoped: true})
+	b.edge(identity, clusterAdmin, "", "issue and approve a client certificate for a privileged user",
+		requirement{Verbs: []string{"create"}, Group: "certificates.k8s.io", Resources: []string{"certificatesigningrequests"}, ClusterScoped: true},
+		requirement{Verbs: []string{"update", "patch"}, Group: "certificates.k8s.io", Resources: []string{"certificatesigningrequests/approval"}, ClusterScoped: true},
+		requirement{Verbs: []string{"approve"}, Group: "certificates.k8s.io", Resources: []string{"signers"}, ClusterScoped: true})
+	b.edge(identity, host, "", "run commands in the pods of the node through the kubelet API",
+		requirement{Verbs: []string{"get", "create"}, Resources: []string{"nodes/proxy"}, ClusterScoped: true})
+
+	for _, ns := range scope.Namespaces {
+		serviceAccounts := &Node{ID: string(ServiceAccountsNode) + ":" + ns, Kind: ServiceAccountsNode, Namespace: ns, Label: "service accounts of " + ns}
+		namespaceAdmin := &Node{ID: string(NamespaceAdminNode) + ":" + ns, Kind: NamespaceAdminNode, Namespace: ns, Label: "admin of " + ns}
+
+		b.edge(identity, namespaceAdmin, ns, "bind the admin cluster role in the namespace",
+			requirement{Verbs: []string{"create", "update", "patch"}, Group: "rbac.authorization.k8s.io", Resources: []string{"rolebindings"}},
+			requirement{Verbs: []string{"bind"}, Group: "rbac.authorization.k8s.io", Resources: []string{"clusterroles"}, AnyScope: true})
+		b.edge(identity, serviceAccounts, ns, "impersonate the service accounts",
+			requirement{Verbs: []string{"impersonate"}, Resources: []string{"serviceaccounts"}})
+		b.edge(identity, serviceAccounts, ns, "request a token for the service accounts",
+			requirement{Verbs: []string{"create"}, Resources: []string{"serviceaccounts/token"}})
+		b.edge(identity, serviceAccounts, ns, "read the service account token secrets",
+			requirement{Verbs: []string{"get", "list"}, Resources: []string{"secrets"}})
+		b.edge(identity, serviceAccounts, ns, "read the token mounted in a running pod",
+			requirement{Verbs: []string{"create"}, Resources: []string{"pods/exec"}})
+
+		for _, workload := range workloadRequirements {
+			b.edge(identity, serviceAccounts, ns, "run a pod with any service account of the namespace", workload)
+			b.edge(identity, host, ns, "run a privileged or host path pod", workload)
+		}
+
+		if b.nodes[namespaceAdmin.ID] != nil {
+			b.add(namespaceAdmin, serviceAccounts, "run a pod with any service account of the namespace")
+			b.add(namespaceAdmin, host, "run a privileged or host path pod")
+		}
+	}
+
+	if b.nodes[host.ID] != nil {
+		kubeSystem := &Node{ID: string(ServiceAccountsNode) + ":kube-system", Kind: ServiceAccountsNode, Namespace: "kube-system", Label: "service accounts of kube-system"}
+		b.add(host, kubeSystem, "read the tokens mounted in the kube-system pods of the node")
+	}
+
+	if kubeSystem := b.nodes[string(ServiceAccountsNode)+":kube-system"]; kubeSystem != nil {
+		b.add(kubeSystem, clusterAdmin, "use the controller permissions of the kube-system service accounts")
+	}
+
+	b.graph.Paths = b.paths(identity)
+
+	return b.graph
+}
+
+// IsEmpty checks if the graph has no escalation step
+func (g *Graph) IsEmpty() bool {
+	return g == nil || len(g.Edges) == 0
+}
+
+// addNode returns the node of the graph with the id of n, adding n when it is missing
+func (b *graphBuilder) addNode(n *Node) *Node {
+	if existing, ok := b.nodes[n.ID]; ok {
+		return existing
+	}
+
+	b.nodes[n.ID] = n
+	b.graph.Nodes = append(b.graph.Nodes, n)
+
+	return n
+}
+
+// edge adds an escalation step when every requirement is satisfied in a namespace
+func (b *graphBuilder) edge(from, to *Node, ns, description string, requirements ...requirement) {
+	permissions := make([]string, 0)
+	for _, req := range requirements {
+		reqNamespace := ns
+		if req.ClusterScoped {
+			reqNamespace = ""
+		}
+
+		allowed := b.allowed(req, reqNamespace)
+		if req.AnyScope && ns != "" {
+			allowed = append(allowed, b.allowed(req, "")...)
+		}
+		if len(allowed) == 0 {
+			return
+		}
+		permissions = append(permissions, allowed...)
+	}
+
+	b.add(from, to, description, permissions...)
+}
+
+// add adds an escalation step, merging the permissions of the steps with the same description
+func (b *graphBuilder) add(from, to *Node, description string, permissions ...string) {
+	from, to = b.addNode(from), b.addNode(to)
+
+	key := [2]string{from.ID + " " + to.ID, description}
+	if existing, ok := b.edges[key]; ok {
+		existing.Permissions = mergeSorted(existing.Permissions, permissions)
+		return
+	}
+
+	e := &Edge{From: from.ID, To: to.ID, Description: description, Permissions: mergeSorted(nil, permissions)}
+	b.edges[key] = e
+	b.graph.Edges = append(b.graph.Edges, e)
+}
+
+// allowed returns the permissions satisfying a requirement in a namespace
+func (b *graphBuilder) allowed(req requirement, ns string) []string {
+	allowed := make([]string, 0)
+	for _, permission := range b.permissions {
+		if permission.NonResourceURL != "" || permission.Namespace != ns || permission.Group != req.Group ||
+			!contains(req.Resources, permission.resource()) {
+			continue
+		}
+
+		for _, verb := range permission.Verbs {
+			if contains(req.Verbs, verb) {
+				allowed = append(allowed, permissionString(permission, verb))
+			}
+		}
+	}
+
+	return allowed
+}
+
+// permissionString returns a verb of a permission with its namespace, like `create pods (default)`
+func permissionString(permission *Permission, verb string) string {
+	if permission.Namespace != "" {
+		return permission.verbString(verb) + " (" + permission.Namespace + ")"
+	}
+
+	return permission.verbString(verb)
+}
+
+// paths returns the shortest path from the start node to every reachable node, the most privileged first
+func (b *graphBuilder) paths(start *Node) []*Path {
+	previous := map[string]*Edge{start.ID: nil}
+	queue := []string{start.ID}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, e := range b.graph.Edges {
+			if e.From != current {
+				continue
+			}
+			if _, visited := previous[e.To]; visited {
+				continue
+			}
+
+			previous[e.To] = e
+			queue = append(queue, e.To)
+		}
+	}
+
+	paths := make([]*Path, 0, len(previous)-1)
+	for id := range previous {
+		if id == start.ID {
+			continue
+		}
+
+		steps := make([]*Edge, 0)
+		for e := previous[id]; e != nil; e = previous[e.From] {
+			steps = append([]*Edge{e}, steps...)
+		}
+
+		paths = append(paths, &Path{Target: id, Label: b.nodes[id].Label, Steps: steps})
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		ki, kj := b.nodes[paths[i].Target].Kind, b.nodes[paths[j].Target].Kind
+		if ki.rank() != kj.rank() {
+			return ki.rank() > kj.rank()
+		}
+		return paths[i].Target < paths[j].Target
+	})
+
+	return paths
+}
+
+// mergeSorted returns the sorted union of two lists, without duplicates
+func mergeSorted(a, b []string) []string {
+	set := make(map[string]struct{}, len(a)+len(b))
+	for _, item := range append(append([]string{}, a...), b...) {
+		set[item] = struct{}{}
+	}
+
+	return sortedKeys(set)
+}
+
+// String returns the node ids of a path, like `identity -> namespace-admin:default -> host`
+func (p *Path) String() string {
+	labels := make([]string, 0, len(p.Steps)+1)
+	for i, step := range p.Steps {
+		if i == 0 {
+			labels = append(labels, step.From)
+		}
+		labels = append(labels, step.To)
+	}
+
+	return strings.Join(labels, " -> ")
+}
@@ -0,0 +1,129 @@
+package risk
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildEscalationGraph(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []*Permission
+		paths       []string
+	}{
+		{
+			name: "namespace admin by binding",
+			permissions: []*Permission{
+				{Group: "rbac.authorization.k8s.io", Resource: "rolebindings", Namespace: "apps", Verbs: []string{"create"}},
+				{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Verbs: []string{"bind"}},
+			},
+			paths: []string{
+				"identity -> namespace-admin:apps -> host -> service-accounts:kube-system -> cluster-admin",
+				"identity -> namespace-admin:apps -> host",
+				"identity -> namespace-admin:apps",
+				"identity -> namespace-admin:apps -> service-accounts:apps",
+				"identity -> namespace-admin:apps -> host -> service-accounts:kube-system",
+			},
+		},
+		{
+			name: "namespace admin by binding with a namespaced bind",
+			permissions: []*Permission{
+				{Group: "rbac.authorization.k8s.io", Resource: "rolebindings", Namespace: "apps", Verbs: []string{"create"}},
+				{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Namespace: "apps", Verbs: []string{"bind"}},
+			},
+			paths: []string{
+				"identity -> namespace-admin:apps -> host -> service-accounts:kube-system -> cluster-admin",
+				"identity -> namespace-admin:apps -> host",
+				"identity -> namespace-admin:apps",
+				"identity -> namespace-admin:apps -> service-accounts:apps",
+				"identity -> namespace-admin:apps -> host -> service-accounts:kube-system",
+			},
+		},
+		{
+			name: "namespaced bind in another namespace",
+			permissions: []*Permission{
+				{Group: "rbac.authorization.k8s.io", Resource: "rolebindings", Namespace: "apps", Verbs: []string{"create"}},
+				{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Namespace: "kube-system", Verbs: []string{"bind"}},
+			},
+			paths: []string{},
+		},
+		{
+			name: "pod creation in kube-system",
+			permissions: []*Permission{
+				{Resource: "pods", Namespace: "kube-system", Verbs: []string{"create"}},
+			},
+			paths: []string{
+				"identity -> service-accounts:kube-system -> cluster-admin",
+				"identity -> host",
+				"identity -> service-accounts:kube-system",
+			},
+		},
+		{
+			name: "get pods/exec without create",
+			permissions: []*Permission{
+				{Resource: "pods", SubResource: "exec", Namespace: "apps", Verbs: []string{"get"}},
+			},
+			paths: []string{},
+		},
+		{
+			name: "create pods/exec",
+			permissions: []*Permission{
+				{Resource: "pods", SubResource: "exec", Namespace: "apps", Verbs: []string{"create"}},
+			},
+			paths: []string{
+				"identity -> service-accounts:apps",
+			},
+		},
+		{
+			name: "bind without role bindings",
+			permissions: []*Permission{
+				{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Verbs: []string{"bind"}},
+				{Resource: "pods", Namespace: "apps", Verbs: []string{"get", "list"}},
+			},
+			paths: []string{},
+		},
+	}
+
+	scope := Scope{Namespaces: []string{"apps", "kube-system"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := BuildEscalationGraph(tt.permissions, scope)
+
+			paths := make([]string, 0)
+			for _, path := range graph.Paths {
+				paths = append(paths, path.String())
+			}
+
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("expected paths %v, got %v", tt.paths, paths)
+			}
+
+			if graph.IsEmpty() != (len(tt.paths) == 0) {
+				t.Errorf("unexpected empty graph %v", graph.IsEmpty())
+			}
+		})
+	}
+}
+
+func TestGraphWriteDOT(t *testing.T) {
+	graph := BuildEscalationGraph([]*Permission{
+		{Resource: "secrets", Namespace: "apps", Verbs: []string{"list"}},
+	}, Scope{Namespaces: []string{"apps"}})
+
+	buffer := &bytes.Buffer{}
+	if err := graph.WriteDOT(buffer, `ci "deployer"`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`digraph "ci \"deployer\"" {`,
+		`"identity" [label="identity", shape=ellipse, color=black];`,
+		`"identity" -> "service-accounts:apps" [label="read the service account token secrets\nlist secrets (apps)"];`,
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("expected %s in\n%s", expected, buffer.String())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package risk
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Severity is the impact of the permissions matched by a Rule
+type Severity string
+
+const (
+	// Critical permissions give a direct path to cluster admin or to the nodes
+	Critical Severity = "critical"
+	// High permissions give access to other identities, sensitive data or workloads
+	High Severity = "high"
+	// Medium permissions are useful steps of an escalation
+	Medium Severity = "medium"
+	// Low permissions disclose information about the cluster
+	Low Severity = "low"
+)
+
+// Rank returns the order of the severity, higher is more severe, and 0 for an unknown severity
+func (s Severity) Rank() int {
+	switch s {
+	case Critical:
+		return 4
+	case High:
+		return 3
+	case Medium:
+		return 2
+	case Low:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Wildcard matches any api group, resource, non-resource URL or verb of a Rule
+const Wildcard = "*"
+
+// Rule tags the allowed verbs of resources and non-resource URLs with a risk
+//
+// Like RBAC rules, a permission matches when its group, resource and verb are listed. Resources
+// include their sub-resource, like `pods/exec`, and the core group is the empty string
+type Rule struct {
+	ID              string   `json:"id"`
+	Title           string   `json:"title"`
+	Description     string   `json:"description,omitempty"`
+	Severity        Severity `json:"severity"`
+	APIGroups       []string `json:"apiGroups,omitempty"`
+	Resources       []string `json:"resources,omitempty"`
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty"`
+	Verbs           []string `json:"verbs"`
+	// ClusterWide matches namespaced resources only when they are allowed in every namespace
+	// of the cluster, which is known when all namespaces are analyzed
+	ClusterWide bool `json:"clusterWide,omitempty"`
+	// Requires are permissions that must all be allowed too, in any namespace, for the rule to match
+	Requires []*Requirement `json:"requires,omitempty"`
+}
+
+// Requirement is a permission needed together with the permissions matched by a Rule, like the
+// approval of a signer together with the approval of the certificate signing requests
+type Requirement struct {
+	APIGroups []string `json:"apiGroups"`
+	Resources []string `json:"resources"`
+	Verbs     []string `json:"verbs"`
+}
+
+// matches checks if a verb of a resource permission satisfies the requirement
+func (req *Requirement) matches(permission *Permission, verb string) bool {
+	return permission.NonResourceURL == "" && contains(req.Verbs, verb) &&
+		contains(req.APIGroups, permission.Group) && contains(req.Resources, permission.resource())
+}
+
+// Validate checks the rule has an id, a title, a known severity and something to match
+func (r *Rule) Validate() error {
+	switch {
+	case r.ID == "":
+		return fmt.Errorf("rule without id")
+	case r.Title == "":
+		return fmt.Errorf("rule [%s] without title", r.ID)
+	case r.Severity.Rank() == 0:
+		return fmt.Errorf("rule [%s] has unknown severity [%s]", r.ID, r.Severity)
+	case len(r.Verbs) == 0:
+		return fmt.Errorf("rule [%s] without verbs", r.ID)
+	case len(r.Resources) == 0 && len(r.NonResourceURLs) == 0:
+		return fmt.Errorf("rule [%s] without resources or non-resource urls", r.ID)
+	case len(r.Resources) > 0 && len(r.APIGroups) == 0:
+		return fmt.Errorf("rule [%s] with resources %v without api groups", r.ID, r.Resources)
+	}
+
+	for _, req := range r.Requires {
+		if len(req.APIGroups) == 0 || len(req.Resources) == 0 || len(req.Verbs) == 0 {
+			return fmt.Errorf("rule [%s] has a requirement without api groups, resources or verbs", r.ID)
+		}
+	}
+
+	return nil
+}
+
+// Matches checks if the rule matches a verb of a permission, ignoring its namespace
+func (r *Rule) Matches(permission *Permission, verb string) bool {
+	if !contains(r.Verbs, verb) {
+		return false
+	}
+
+	if permission.NonResourceURL != "" {
+		for _, url := range r.NonResourceURLs {
+			if url == Wildcard || url == permission.NonResourceURL ||
+				(strings.HasSuffix(url, Wildcard) && strings.HasPrefix(permission.NonResourceURL, strings.TrimSuffix(url, Wildcard))) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return contains(r.APIGroups, permission.Group) && contains(r.Resources, permission.resource())
+}
+
+// contains checks if a value, or the wildcard, is in the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value || item == Wildcard {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rulesFile is the format of a file with custom rules
+type rulesFile struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// ReadRulesFile reads the custom rules of a YAML or JSON file, with a `rules` list
+func ReadRulesFile(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &rulesFile{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("could not parse rules file: %w", err)
+	}
+
+	for _, rule := range file.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return file.Rules, nil
+}
@@ -3,7 +3,8 @@ package runner
 import (
 	"context"
 	"errors"
-	"sync"
+	"net/http"
+	"net/url"
 
 	myK8s "github.com/ing-bank/kal/pkg/kubernetes"
 	"github.com/ing-bank/kal/pkg/types"
@@ -11,22 +12,85 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/util/flowcontrol"
 )
 
-// FromOptions creates a KAL runner based on provided options
-func FromOptions(o *types.Options) *Runner {
+// Option configures a Runner
+type Option func(r *Runner)
+
+// WithAccessReviewer sets the AccessReviewer used to test the verbs, instead of SelfSubjectAccessReview
+func WithAccessReviewer(reviewer AccessReviewer) Option {
+	return func(r *Runner) {
+		r.reviewer = reviewer
+	}
+}
+
+// WithDiscoverer sets the Discoverer used to list resources, instead of the discovery API
+func WithDiscoverer(discoverer Discoverer) Option {
+	return func(r *Runner) {
+		r.discoverer = discoverer
+	}
+}
+
+// WithSink sets the ResultSink receiving the results of the analysis
+func WithSink(sink ResultSink) Option {
+	return func(r *Runner) {
+		r.sink = sink
+	}
+}
+
+// WithResultCallback sets a function called with every result of the analysis
+func WithResultCallback(callback func(result *Result)) Option {
+	return WithSink(ResultSinkFunc(func(result *Result) error {
+		callback(result)
+		return nil
+	}))
+}
+
+// New creates a KAL runner using a Kubernetes client
+//
+// Unless replaced by options, the access reviews use SelfSubjectAccessReview, the resources
+// are listed with the discovery API and the results are only returned by Exec
+func New(client kubernetes.Interface, opts ...Option) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
 	r := &Runner{
-		Context:    context.Background(),
-		JSONOutput: o.Output.JSON,
-		Namespace:  o.Kubernetes.Namespace,
-		ShowAll:    o.Output.ShowAll,
-		ShowReason: o.Output.ShowReason,
-		outputChan: make(chan *Result),
-		outputWg:   sync.WaitGroup{},
+		KubernetesClient: client,
+		Context:          ctx,
+		Concurrency:      1,
+		cancel:           cancel,
 	}
-	types.InitAurora(o)
 
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.reviewer == nil && client != nil {
+		r.reviewer = NewSelfSubjectReviewer(client)
+	}
+
+	if r.discoverer == nil && client != nil {
+		r.discoverer = NewAPIDiscoverer(client.Discovery())
+	}
+
+	return r
+}
+
+// FromOptions creates a KAL runner based on provided options
+func FromOptions(o *types.Options, opts ...Option) *Runner {
+	client, err := NewClient(o)
+	if err != nil {
+		gologger.Fatal().Msgf("invalid kubernetes options. could not create a client. error: %s\n", err)
+	}
+
+	return newFromOptions(client, o, opts...)
+}
+
+// NewClient creates a Kubernetes client based on provided options
+//
+// It uses the provided server url and authentication, then the kubeconfig, then the
+// service account of the POD running KAL
+func NewClient(o *types.Options) (*kubernetes.Clientset, error) {
 	var client *kubernetes.Clientset
 	var err error
 
@@ -40,22 +104,34 @@ func FromOptions(o *types.Options) *Runner {
 		client, err = getInPodClient(o)
 	}
 
-	if err != nil {
-		gologger.Error().
-			Str("error", err.Error()).
-			Msg("could not create kubernetes client\n")
-	}
-
 	if client == nil {
-		gologger.Fatal().
-			Str("error", "invalid kubernetes options. could not create a client")
+		return nil, err
 	}
 
-	r.KubernetesClient = client
+	return client, nil
+}
 
-	if r.Namespace == "" {
-		r.Namespace = o.Kubernetes.Namespace
-	}
+// newFromOptions creates a KAL runner using a Kubernetes client, based on provided options
+func newFromOptions(client kubernetes.Interface, o *types.Options, opts ...Option) *Runner {
+	r := New(client, opts...)
+
+	r.ServerURL = o.Kubernetes.ServerURL
+	r.Namespaces = o.Kubernetes.Namespaces
+	r.KubeContext = o.Kubernetes.KubeContext
+	r.KubeCluster = o.Kubernetes.KubeCluster
+	r.NamespacesFile = o.Kubernetes.NamespacesFile
+	r.AllNamespaces = o.Kubernetes.AllNamespaces
+	r.Impersonation = o.Kubernetes.Impersonation()
+	r.token = o.Kubernetes.ApiToken
+	r.VerifyToken = o.Kubernetes.VerifyToken
+	r.JWKSFile = o.Kubernetes.JWKSFile
+	r.AllVerbs = o.Enumeration.AllVerbs
+	r.Concurrency = o.Enumeration.Concurrency
+	r.MaxRequests = o.Enumeration.MaxRequests
+	r.RulesReview = o.Enumeration.RulesReview
+	r.NonResourceURLs = o.Enumeration.NonResourceURLs
+	r.SkipNonResource = o.Enumeration.SkipNonResource
+	r.ShowAll = o.Output.ShowAll
 
 	return r
 }
@@ -65,7 +141,7 @@ func getCustomClient(o *types.Options) (*kubernetes.Clientset, error) {
 		return nil, errors.New("invalid kubernetes options")
 	}
 
-	if o.Kubernetes.ServerURL == "" || o.Kubernetes.ApiToken == "" {
+	if o.Kubernetes.ServerURL == "" || (o.Kubernetes.ApiToken == "" && o.Kubernetes.ClientCertificate == "") {
 		return nil, errors.New("invalid configuration for kubernetes custom client")
 	}
 
@@ -78,6 +154,8 @@ func getCustomClient(o *types.Options) (*kubernetes.Clientset, error) {
 		},
 	}
 	setDefaultConfigOptions(config, o)
+	resolveDefaultNamespace(o.Kubernetes,
+		namespaceCandidate{source: namespaceSourceToken, namespace: myK8s.NamespaceFromToken(config.BearerToken)})
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -92,23 +170,32 @@ func getKubeConfigClient(o *types.Options) (*kubernetes.Clientset, error) {
 		return nil, errors.New("invalid kubernetes options")
 	}
 
-	if o.Kubernetes.KubeConfigPath == "" {
-		return nil, errors.New("invalid configuration file for kubernetes")
-	}
+	clientConfig := kubeConfigClientConfig(o.Kubernetes)
 
-	config, err := clientcmd.BuildConfigFromFlags(o.Kubernetes.ServerURL, o.Kubernetes.KubeConfigPath)
+	config, err := clientConfig.ClientConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	var contextNamespace string
+	if rawConfig, err := clientConfig.RawConfig(); err == nil {
+		o.Kubernetes.KubeContext, o.Kubernetes.KubeCluster = kubeConfigNames(rawConfig, o.Kubernetes)
+		if kubeContext, ok := rawConfig.Contexts[o.Kubernetes.KubeContext]; ok {
+			contextNamespace = kubeContext.Namespace
+		}
+	}
+
 	if o.Kubernetes.ApiToken != "" {
 		gologger.Warning().
 			Msg("using provided service account token instead of kubeconfig configuration")
-		config.BearerToken = o.Kubernetes.ApiToken
-	} else {
+		useBearerToken(config, o.Kubernetes.ApiToken)
+	} else if o.Kubernetes.ClientCertificate == "" {
 		o.Kubernetes.ApiToken = config.BearerToken
 	}
 	setDefaultConfigOptions(config, o)
+	resolveDefaultNamespace(o.Kubernetes,
+		namespaceCandidate{source: namespaceSourceKubeConfig, namespace: contextNamespace},
+		namespaceCandidate{source: namespaceSourceToken, namespace: myK8s.NamespaceFromToken(config.BearerToken)})
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -118,12 +205,81 @@ func getKubeConfigClient(o *types.Options) (*kubernetes.Clientset, error) {
 	return client, nil
 }
 
+// kubeConfigClientConfig returns the kubeconfig client configuration
+//
+// Without an explicit path, the files of the KUBECONFIG environment variable are merged,
+// falling back to `$HOME/.kube/config`. The context, cluster, user and server url are
+// overridden by the provided options
+func kubeConfigClientConfig(ko *types.KubernetesOptions) clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = ko.KubeConfigPath
+
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: ko.KubeContext,
+		Context: clientcmdapi.Context{
+			Cluster:  ko.KubeCluster,
+			AuthInfo: ko.KubeUser,
+		},
+		ClusterInfo: clientcmdapi.Cluster{
+			Server: ko.ServerURL,
+		},
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+// useClientCertificate replaces the authentication of a configuration by a x509 client certificate
+func useClientCertificate(config *rest.Config, certFile, keyFile string) {
+	config.BearerToken = ""
+	config.BearerTokenFile = ""
+	config.Username = ""
+	config.Password = ""
+	config.ExecProvider = nil
+	config.AuthProvider = nil
+	config.CertData = nil
+	config.CertFile = certFile
+	config.KeyData = nil
+	config.KeyFile = keyFile
+}
+
+// kubeConfigNames returns the context and cluster names used from a kubeconfig
+func kubeConfigNames(rawConfig clientcmdapi.Config, ko *types.KubernetesOptions) (string, string) {
+	contextName := ko.KubeContext
+	if contextName == "" {
+		contextName = rawConfig.CurrentContext
+	}
+
+	clusterName := ko.KubeCluster
+	if kubeContext, ok := rawConfig.Contexts[contextName]; ok && clusterName == "" {
+		clusterName = kubeContext.Cluster
+	}
+
+	return contextName, clusterName
+}
+
+// useBearerToken replaces the authentication of a configuration by a bearer token
+func useBearerToken(config *rest.Config, token string) {
+	config.BearerToken = token
+	config.BearerTokenFile = ""
+	config.Username = ""
+	config.Password = ""
+	config.ExecProvider = nil
+	config.AuthProvider = nil
+	config.CertData = nil
+	config.CertFile = ""
+	config.KeyData = nil
+	config.KeyFile = ""
+}
+
 func getInPodClient(o *types.Options) (*kubernetes.Clientset, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
 	}
 	setDefaultConfigOptions(config, o)
+	resolveDefaultNamespace(o.Kubernetes,
+		namespaceCandidate{source: namespaceSourcePod, namespace: readInPodNamespace()},
+		namespaceCandidate{source: namespaceSourceToken, namespace: myK8s.NamespaceFromToken(config.BearerToken)})
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -134,40 +290,62 @@ func getInPodClient(o *types.Options) (*kubernetes.Clientset, error) {
 }
 
 func setDefaultConfigOptions(config *rest.Config, o *types.Options) {
-	// remove rate limiting...
 	if o.Kubernetes.QPS > 0 {
-		config.QPS = o.Kubernetes.QPS
-	} else {
-		config.QPS = 400
+		config.QPS = float32(o.Kubernetes.QPS)
 	}
 
 	if o.Kubernetes.Burst > 0 {
 		config.Burst = o.Kubernetes.Burst
-	} else {
-		config.Burst = 400
 	}
 
+	// remove rate limiting...
+
 	if o.Kubernetes.NoRateLimit {
 		config.RateLimiter = flowcontrol.NewFakeAlwaysRateLimiter()
 	}
 
 	config.UserAgent = kalUserAgent
 
-	if o.Kubernetes.InsecureTLS {
-		config.TLSClientConfig = rest.TLSClientConfig{
-			Insecure: o.Kubernetes.InsecureTLS,
+	if o.Kubernetes.UserToImpersonate != "" {
+		config.Impersonate = o.Kubernetes.Impersonation()
+	}
+
+	if o.Kubernetes.ClientCertificate != "" {
+		useClientCertificate(config, o.Kubernetes.ClientCertificate, o.Kubernetes.ClientKey)
+	}
+
+	if o.Kubernetes.CertificateAuthority != "" {
+		config.CAFile = o.Kubernetes.CertificateAuthority
+		config.CAData = nil
+	}
+
+	if o.Kubernetes.TLSServerName != "" {
+		config.ServerName = o.Kubernetes.TLSServerName
+	}
+
+	if o.Kubernetes.ProxyURL != "" {
+		// invalid urls are rejected when the options are validated, they are never used as a proxy
+		if proxyURL, err := url.Parse(o.Kubernetes.ProxyURL); err == nil && proxyURL.Host != "" {
+			config.Proxy = http.ProxyURL(proxyURL)
 		}
 	}
 
-	if o.Kubernetes.Namespace == "" {
-		o.Kubernetes.Namespace = myK8s.GrabNamespaceFromToken(config.BearerToken)
+	if o.Kubernetes.InsecureTLS {
+		// the client certificates are kept, only the server verification is disabled
+		config.Insecure = true
+		config.CAData = nil
+		config.CAFile = ""
 	}
+
+	o.Kubernetes.ServerURL = config.Host
 }
 
 // Close stops the execution of the runner
 //
-// It closes the output chanel and clear any waiting group
+// It cancels the context of the runner: the running analysis stops sending access reviews, and
+// the next calls to Exec fail
 func (r *Runner) Close() {
-	close(r.outputChan)
-	r.outputWg.Done()
+	if r.cancel != nil {
+		r.cancel()
+	}
 }
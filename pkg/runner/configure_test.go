@@ -0,0 +1,213 @@
+package runner
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ing-bank/kal/pkg/types"
+	"github.com/projectdiscovery/goflags"
+	"k8s.io/client-go/rest"
+)
+
+func TestSetDefaultConfigOptionsImpersonation(t *testing.T) {
+	o := &types.Options{Kubernetes: &types.KubernetesOptions{
+		UserToImpersonate:   "jane",
+		GroupsToImpersonate: goflags.StringSlice{"dev", "ops"},
+		UIDToImpersonate:    "42",
+		ExtraToImpersonate:  goflags.StringSlice{"scopes=view", "scopes=edit", "team=payments"},
+	}}
+
+	config := &rest.Config{Host: "https://kubernetes.example.com"}
+	setDefaultConfigOptions(config, o)
+
+	expected := rest.ImpersonationConfig{
+		UserName: "jane",
+		UID:      "42",
+		Groups:   []string{"dev", "ops"},
+		Extra:    map[string][]string{"scopes": {"view", "edit"}, "team": {"payments"}},
+	}
+	if !reflect.DeepEqual(config.Impersonate, expected) {
+		t.Errorf("expected impersonation %+v, got %+v", expected, config.Impersonate)
+	}
+
+	config = &rest.Config{Host: "https://kubernetes.example.com"}
+	setDefaultConfigOptions(config, &types.Options{Kubernetes: &types.KubernetesOptions{}})
+	if !reflect.DeepEqual(config.Impersonate, rest.ImpersonationConfig{}) {
+		t.Errorf("expected no impersonation, got %+v", config.Impersonate)
+	}
+}
+
+func TestSetDefaultConfigOptionsTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		options types.KubernetesOptions
+		config  rest.Config
+		// expected is the resulting tls configuration, and proxy the proxy url, empty without a proxy
+		expected rest.TLSClientConfig
+		proxy    string
+	}{
+		{
+			name:     "kubeconfig tls configuration",
+			config:   rest.Config{TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca"), CertFile: "kube.crt", KeyFile: "kube.key"}},
+			expected: rest.TLSClientConfig{CAData: []byte("ca"), CertFile: "kube.crt", KeyFile: "kube.key"},
+		},
+		{
+			name:     "certificate authority",
+			options:  types.KubernetesOptions{CertificateAuthority: "/etc/kal/ca.crt"},
+			config:   rest.Config{TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca")}},
+			expected: rest.TLSClientConfig{CAFile: "/etc/kal/ca.crt"},
+		},
+		{
+			name:     "client certificate",
+			options:  types.KubernetesOptions{ClientCertificate: "client.crt", ClientKey: "client.key"},
+			config:   rest.Config{BearerToken: "token", TLSClientConfig: rest.TLSClientConfig{CertData: []byte("cert"), KeyData: []byte("key")}},
+			expected: rest.TLSClientConfig{CertFile: "client.crt", KeyFile: "client.key"},
+		},
+		{
+			name:     "tls server name",
+			options:  types.KubernetesOptions{TLSServerName: "kubernetes.default.svc"},
+			expected: rest.TLSClientConfig{ServerName: "kubernetes.default.svc"},
+		},
+		{
+			name:     "insecure",
+			options:  types.KubernetesOptions{InsecureTLS: true, ClientCertificate: "client.crt", ClientKey: "client.key"},
+			config:   rest.Config{TLSClientConfig: rest.TLSClientConfig{CAFile: "kube-ca.crt", CAData: []byte("ca")}},
+			expected: rest.TLSClientConfig{Insecure: true, CertFile: "client.crt", KeyFile: "client.key"},
+		},
+		{
+			name:     "certificate authority and insecure",
+			options:  types.KubernetesOptions{CertificateAuthority: "/etc/kal/ca.crt", InsecureTLS: true},
+			expected: rest.TLSClientConfig{Insecure: true},
+		},
+		{
+			name:    "proxy",
+			options: types.KubernetesOptions{ProxyURL: "http://proxy.example.com:3128"},
+			proxy:   "http://proxy.example.com:3128",
+		},
+		{
+			name:    "invalid proxy",
+			options: types.KubernetesOptions{ProxyURL: "://proxy"},
+		},
+		{
+			name:    "proxy without host",
+			options: types.KubernetesOptions{ProxyURL: "proxy.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Host = "https://kubernetes.example.com"
+
+			setDefaultConfigOptions(&config, &types.Options{Kubernetes: &tt.options})
+
+			if !reflect.DeepEqual(config.TLSClientConfig, tt.expected) {
+				t.Errorf("expected tls configuration %+v, got %+v", tt.expected, config.TLSClientConfig)
+			}
+
+			if tt.options.ClientCertificate != "" && config.BearerToken != "" {
+				t.Error("expected the client certificate to replace the token")
+			}
+
+			if tt.proxy == "" {
+				if config.Proxy != nil {
+					t.Error("expected no proxy")
+				}
+				return
+			}
+
+			if config.Proxy == nil {
+				t.Fatal("expected a proxy")
+			}
+
+			request, _ := http.NewRequest(http.MethodGet, config.Host, nil)
+			if proxyURL, err := config.Proxy(request); err != nil || proxyURL.String() != tt.proxy {
+				t.Errorf("expected proxy %s, got %v (%v)", tt.proxy, proxyURL, err)
+			}
+		})
+	}
+}
+
+const twoContextsKubeConfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev.example.com:6443
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+    namespace: apps
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+    namespace: payments
+users:
+- name: dev-user
+  user:
+    token: dev-token
+- name: prod-user
+  user:
+    token: prod-token
+`
+
+func TestGetKubeConfigClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(twoContextsKubeConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		context   string
+		server    string
+		cluster   string
+		namespace string
+		token     string
+		err       bool
+	}{
+		{"current context", "", "dev.example.com:6443", "dev-cluster", "apps", "dev-token", false},
+		{"dev context", "dev", "dev.example.com:6443", "dev-cluster", "apps", "dev-token", false},
+		{"prod context", "prod", "prod.example.com", "prod-cluster", "payments", "prod-token", false},
+		{"unknown context", "staging", "", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ko := &types.KubernetesOptions{KubeConfigPath: path, KubeContext: tt.context}
+
+			client, err := getKubeConfigClient(&types.Options{Kubernetes: ko})
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error for an unknown context")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if server := client.CoreV1().RESTClient().Get().URL().Host; server != tt.server {
+				t.Errorf("expected server %s, got %s", tt.server, server)
+			}
+
+			if ko.KubeCluster != tt.cluster || ko.ApiToken != tt.token {
+				t.Errorf("expected cluster %s and token %s, got %s and %s", tt.cluster, tt.token, ko.KubeCluster, ko.ApiToken)
+			}
+
+			if !reflect.DeepEqual([]string(ko.Namespaces), []string{tt.namespace}) {
+				t.Errorf("expected namespace %s, got %v", tt.namespace, ko.Namespaces)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/ing-bank/kal/pkg/types"
+	"github.com/projectdiscovery/gologger"
+)
+
+// KubeConfigContexts returns the sorted names of every context of the kubeconfig
+func KubeConfigContexts(ko *types.KubernetesOptions) ([]string, error) {
+	rawConfig, err := kubeConfigClientConfig(ko).RawConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make([]string, 0, len(rawConfig.Contexts))
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+
+	if len(contexts) == 0 {
+		return nil, errors.New("no contexts found in kubeconfig")
+	}
+
+	return contexts, nil
+}
+
+// ExecContexts runs the analysis of every kubeconfig context, with a client per context
+//
+// At most parallel contexts are analyzed at the same time. A failing context does not stop
+// the analysis of the other ones, its error is returned as a TargetError
+func ExecContexts(o *types.Options, contexts []string, parallel int, opts ...Option) []*TargetError {
+	return execTargets(len(contexts), parallel, func(i int) *TargetError {
+		return execContext(o, contexts[i], opts...)
+	})
+}
+
+// execContext runs the analysis of a kubeconfig context
+func execContext(o *types.Options, contextName string, opts ...Option) *TargetError {
+	// every context has its own copy of the kubernetes options, as the clients update them
+	ko := *o.Kubernetes
+	ko.KubeContext = contextName
+
+	contextOptions := *o
+	contextOptions.Kubernetes = &ko
+
+	client, err := getKubeConfigClient(&contextOptions)
+	if err != nil {
+		return &TargetError{Target: contextTarget(&ko), Err: err}
+	}
+
+	gologger.Info().Msgf("analyzing context %s (cluster %s)\n", ko.KubeContext, ko.KubeCluster)
+
+	r := newFromOptions(client, &contextOptions, opts...)
+	if _, err := r.Exec(); err != nil {
+		target := r.target
+		if target == nil {
+			target = contextTarget(&ko)
+		}
+
+		return &TargetError{Target: target, Err: err}
+	}
+
+	return nil
+}
+
+// contextTarget returns the Target of a kubeconfig context that could not be analyzed
+func contextTarget(ko *types.KubernetesOptions) *Target {
+	target := &Target{
+		ServerURL:  ko.ServerURL,
+		Context:    ko.KubeContext,
+		Cluster:    ko.KubeCluster,
+		Identity:   identityFromImpersonation(ko.Impersonation()),
+		Namespaces: ko.Namespaces,
+	}
+
+	if target.Namespaces == nil {
+		target.Namespaces = []string{}
+	}
+
+	rawConfig, err := kubeConfigClientConfig(ko).RawConfig()
+	if err != nil {
+		return target
+	}
+
+	_, target.Cluster = kubeConfigNames(rawConfig, ko)
+	if cluster, ok := rawConfig.Clusters[target.Cluster]; ok && target.ServerURL == "" {
+		target.ServerURL = cluster.Server
+	}
+
+	return target
+}
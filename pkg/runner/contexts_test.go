@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ing-bank/kal/pkg/types"
+	v1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// newFakeAPIServer serves the discovery of the pods and allows to get them with the valid token
+func newFakeAPIServer(t *testing.T, validToken string) *httptest.Server {
+	mux := http.NewServeMux()
+
+	reply := func(w http.ResponseWriter, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}
+
+	mux.HandleFunc("GET /api", func(w http.ResponseWriter, _ *http.Request) {
+		reply(w, &metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+	})
+	mux.HandleFunc("GET /apis", func(w http.ResponseWriter, _ *http.Request) {
+		reply(w, &metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}})
+	})
+	mux.HandleFunc("GET /api/v1", func(w http.ResponseWriter, _ *http.Request) {
+		reply(w, &metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: []string{"get", "list"}}},
+		})
+	})
+	mux.HandleFunc("POST /apis/authorization.k8s.io/v1/selfsubjectaccessreviews", func(w http.ResponseWriter, r *http.Request) {
+		// the client sends json or protobuf requests
+		body, _ := io.ReadAll(r.Body)
+		object, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, nil)
+		review, ok := object.(*v1.SelfSubjectAccessReview)
+		if err != nil || !ok {
+			http.Error(w, fmt.Sprintf("invalid access review: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = attributes != nil && attributes.Resource == "pods" && attributes.Verb == "get"
+		reply(w, review)
+	})
+
+	// the kubeconfig credentials are only used with tls
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+validToken {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestExecContexts(t *testing.T) {
+	server := newFakeAPIServer(t, "valid-token")
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: fake
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+contexts:
+- name: dev
+  context: {cluster: fake, user: dev-user, namespace: apps}
+- name: expired
+  context: {cluster: fake, user: expired-user, namespace: apps}
+- name: prod
+  context: {cluster: fake, user: prod-user, namespace: payments}
+users:
+- name: dev-user
+  user: {token: valid-token}
+- name: expired-user
+  user: {token: expired-token}
+- name: prod-user
+  user: {token: valid-token}
+`, server.URL)
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &types.Options{
+		Kubernetes:  &types.KubernetesOptions{KubeConfigPath: path},
+		Enumeration: &types.EnumerationOptions{Concurrency: 1, SkipNonResource: true},
+		Output:      &types.OutputOptions{},
+	}
+
+	contexts, err := KubeConfigContexts(o.Kubernetes)
+	if err != nil {
+		t.Fatalf("could not list the contexts: %s", err)
+	}
+
+	if !reflect.DeepEqual(contexts, []string{"dev", "expired", "prod"}) {
+		t.Fatalf("unexpected contexts %v", contexts)
+	}
+
+	reportSink := NewReportSink()
+	for _, contextError := range ExecContexts(o, contexts, 2, WithSink(reportSink)) {
+		reportSink.AddError(contextError.Target, contextError.Err)
+	}
+
+	targetReports := make(map[string]*TargetReport)
+	for _, targetReport := range reportSink.Report().Targets {
+		targetReports[targetReport.Context] = targetReport
+	}
+
+	if len(targetReports) != len(contexts) {
+		t.Fatalf("expected a target per context, got %d", len(targetReports))
+	}
+
+	if targetReport := targetReports["expired"]; targetReport.Error == "" || len(targetReport.Results) != 0 {
+		t.Errorf("expected the expired context to be reported with an error, got %+v", targetReport)
+	}
+
+	for context, namespace := range map[string]string{"dev": "apps", "prod": "payments"} {
+		targetReport := targetReports[context]
+		if targetReport.Error != "" {
+			t.Errorf("unexpected error in context %s: %s", context, targetReport.Error)
+			continue
+		}
+
+		if targetReport.Cluster != "fake" || !reflect.DeepEqual(targetReport.Namespaces, []string{namespace}) {
+			t.Errorf("unexpected target of context %s: %s %v", context, targetReport.Cluster, targetReport.Namespaces)
+		}
+
+		if len(targetReport.Results) != 1 || targetReport.Results[0].Namespace != namespace ||
+			!reflect.DeepEqual(targetReport.Results[0].AllowedVerbs, []string{"get"}) {
+			t.Errorf("expected get pods in %s for context %s, got %+v", namespace, context, targetReport.Results)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"sort"
+	"strings"
+
+	myK8s "github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	// IdentitySourceSelfSubjectReview is the source of an identity returned by the Kubernetes API
+	IdentitySourceSelfSubjectReview = "SelfSubjectReview"
+	// IdentitySourceImpersonation is the source of an identity impersonated by the client
+	IdentitySourceImpersonation = "impersonation"
+	// IdentitySourceToken is the source of an identity read from the unverified claims of the token
+	IdentitySourceToken = "token"
+)
+
+// resolveIdentity returns the identity of the authentication of the runner
+//
+// The identity is requested to the Kubernetes API with a SelfSubjectReview. On older servers, it
+// falls back to the impersonated identity, then to the claims of the token
+func (r *Runner) resolveIdentity() *Identity {
+	if identityReviewer, ok := r.reviewer.(IdentityReviewer); ok {
+		identity, err := identityReviewer.ReviewIdentity(r.Context)
+		if err == nil {
+			return identity
+		}
+
+		gologger.Warning().Msgf("could not review identity, falling back to token claims. error: %s\n", err)
+	}
+
+	if identity := identityFromImpersonation(r.Impersonation); identity != nil {
+		return identity
+	}
+
+	tokenIdentity := myK8s.IdentityFromToken(r.token)
+	if tokenIdentity == nil {
+		return nil
+	}
+
+	return &Identity{
+		Username: tokenIdentity.Username,
+		UID:      tokenIdentity.UID,
+		Groups:   tokenIdentity.Groups,
+		Source:   IdentitySourceToken,
+	}
+}
+
+// String returns the string representation of an Identity
+func (i *Identity) String() string {
+	sb := &strings.Builder{}
+
+	sb.WriteString("user = " + i.Username)
+
+	if i.UID != "" {
+		sb.WriteString(", uid = " + i.UID)
+	}
+
+	if len(i.Groups) > 0 {
+		sb.WriteString(", groups = " + strings.Join(i.Groups, ","))
+	}
+
+	extraKeys := make([]string, 0, len(i.Extra))
+	for key := range i.Extra {
+		extraKeys = append(extraKeys, key)
+	}
+	sort.Strings(extraKeys)
+
+	for _, key := range extraKeys {
+		sb.WriteString(", extra." + key + " = " + strings.Join(i.Extra[key], ","))
+	}
+
+	return sb.String()
+}
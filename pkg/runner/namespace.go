@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ing-bank/kal/pkg/types"
+	"github.com/projectdiscovery/gologger"
+)
+
+// inPodNamespaceFile is the namespace of the service account mounted inside a POD
+const inPodNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// defaultNamespace is analyzed when no source of the resolution chain provides a namespace
+const defaultNamespace = "default"
+
+const (
+	namespaceSourceKubeConfig = "kubeconfig context"
+	namespaceSourcePod        = "pod namespace file"
+	namespaceSourceToken      = "token claims"
+)
+
+// namespaceCandidate is the namespace found by a source of the resolution chain
+type namespaceCandidate struct {
+	source    string
+	namespace string
+}
+
+// resolveDefaultNamespace sets the namespace analyzed when none is provided
+//
+// The namespaces provided as flags are always used. Otherwise the first candidate with a
+// namespace is used, in order: kubeconfig context, in-pod namespace file and token claims,
+// falling back to the `default` namespace
+func resolveDefaultNamespace(ko *types.KubernetesOptions, candidates ...namespaceCandidate) {
+	if len(ko.Namespaces) > 0 || ko.NamespacesFile != "" {
+		gologger.Debug().Msg("using the provided namespaces")
+		return
+	}
+
+	for _, candidate := range candidates {
+		if candidate.namespace != "" {
+			gologger.Info().Msgf("using namespace %s from the %s\n", candidate.namespace, candidate.source)
+			ko.Namespaces = []string{candidate.namespace}
+			return
+		}
+	}
+
+	gologger.Info().Msgf("no namespace found, using namespace %s\n", defaultNamespace)
+	ko.Namespaces = []string{defaultNamespace}
+}
+
+// readInPodNamespace returns the namespace of the service account mounted inside a POD
+func readInPodNamespace() string {
+	namespace, err := os.ReadFile(inPodNamespaceFile)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(namespace))
+}
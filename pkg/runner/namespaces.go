@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// resolveNamespaces returns the namespaces to be analyzed, and if they are every namespace of the cluster
+//
+// With AllNamespaces, the namespaces are listed from the Kubernetes API. When the authentication
+// is not allowed to list namespaces, it falls back to the namespaces provided by the user
+func (r *Runner) resolveNamespaces() ([]string, bool) {
+	if r.AllNamespaces {
+		namespaces, err := r.listNamespaces()
+		if err == nil {
+			return namespaces, true
+		}
+
+		gologger.Warning().Msgf("could not list namespaces, using the provided ones. error: %s\n", err)
+	}
+
+	namespaces := make(map[string]struct{})
+	for _, ns := range r.Namespaces {
+		namespaces[ns] = struct{}{}
+	}
+
+	if r.NamespacesFile != "" {
+		fileNamespaces, err := readNamespacesFile(r.NamespacesFile)
+		if err != nil {
+			gologger.Error().Msgf("could not read namespaces file. error: %s\n", err)
+		}
+
+		for _, ns := range fileNamespaces {
+			namespaces[ns] = struct{}{}
+		}
+	}
+
+	return sortedKeys(namespaces), false
+}
+
+// namespacesPageSize is the number of namespaces listed per request
+const namespacesPageSize = 500
+
+// listNamespaces lists all namespaces of the cluster, a page at a time
+func (r *Runner) listNamespaces() ([]string, error) {
+	if r.KubernetesClient == nil {
+		return nil, errors.New("no kubernetes client to list namespaces")
+	}
+
+	namespaces := make([]string, 0)
+	opts := metav1.ListOptions{Limit: namespacesPageSize}
+	for {
+		namespaceList, err := r.KubernetesClient.
+			CoreV1().
+			Namespaces().
+			List(r.Context, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ns := range namespaceList.Items {
+			namespaces = append(namespaces, ns.Name)
+		}
+
+		if namespaceList.Continue == "" {
+			break
+		}
+		opts.Continue = namespaceList.Continue
+	}
+
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+// readNamespacesFile reads a file containing one namespace per line
+//
+// Empty lines and lines starting with `#` are ignored
+func readNamespacesFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	namespaces := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		namespaces = append(namespaces, line)
+	}
+
+	return namespaces, scanner.Err()
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
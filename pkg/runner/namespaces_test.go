@@ -0,0 +1,180 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// pagedNamespaces returns a reactor listing the namespaces by pages of the requested limit
+func pagedNamespaces(names ...string) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).ListOptions
+
+		start := 0
+		if opts.Continue != "" {
+			start, _ = strconv.Atoi(opts.Continue)
+		}
+
+		end := len(names)
+		if opts.Limit > 0 && start+int(opts.Limit) < end {
+			end = start + int(opts.Limit)
+		}
+
+		list := &corev1.NamespaceList{}
+		for _, name := range names[start:end] {
+			list.Items = append(list.Items, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+		if end < len(names) {
+			list.Continue = strconv.Itoa(end)
+		}
+
+		return true, list, nil
+	}
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "namespaces.txt")
+	if err := os.WriteFile(file, []byte("# teams\napps\n\n  monitoring  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	forbidden := func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", errors.New("not allowed"))
+	}
+
+	// more namespaces than a page, to list them with continue tokens
+	many := make([]string, 0, namespacesPageSize+1)
+	for i := 0; i <= namespacesPageSize; i++ {
+		many = append(many, "ns-"+strconv.Itoa(i))
+	}
+
+	tests := []struct {
+		name           string
+		reactor        k8stesting.ReactionFunc
+		allNamespaces  bool
+		namespaces     []string
+		namespacesFile string
+		expected       []string
+		expectedAll    bool
+	}{
+		{
+			name:       "provided namespaces",
+			namespaces: []string{"b", "a", "b"},
+			expected:   []string{"a", "b"},
+		},
+		{
+			name:           "provided namespaces and file",
+			namespaces:     []string{"default"},
+			namespacesFile: file,
+			expected:       []string{"apps", "default", "monitoring"},
+		},
+		{
+			name:           "missing file",
+			namespaces:     []string{"default"},
+			namespacesFile: filepath.Join(t.TempDir(), "missing.txt"),
+			expected:       []string{"default"},
+		},
+		{
+			name:          "all namespaces",
+			reactor:       pagedNamespaces("kube-system", "default"),
+			allNamespaces: true,
+			namespaces:    []string{"apps"},
+			expected:      []string{"default", "kube-system"},
+			expectedAll:   true,
+		},
+		{
+			name:          "all namespaces in pages",
+			reactor:       pagedNamespaces(many...),
+			allNamespaces: true,
+			expected:      sortedKeys(toSet(many)),
+			expectedAll:   true,
+		},
+		{
+			name:           "forbidden list of namespaces",
+			reactor:        forbidden,
+			allNamespaces:  true,
+			namespaces:     []string{"default"},
+			namespacesFile: file,
+			expected:       []string{"apps", "default", "monitoring"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset()
+			if tt.reactor != nil {
+				client.PrependReactor("list", "namespaces", tt.reactor)
+			}
+
+			r := New(client)
+			r.AllNamespaces = tt.allNamespaces
+			r.Namespaces = tt.namespaces
+			r.NamespacesFile = tt.namespacesFile
+
+			namespaces, all := r.resolveNamespaces()
+			if !reflect.DeepEqual(namespaces, tt.expected) || all != tt.expectedAll {
+				t.Errorf("expected %v (all %t), got %v (all %t)", tt.expected, tt.expectedAll, namespaces, all)
+			}
+		})
+	}
+}
+
+func TestReadNamespacesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "namespaces.txt")
+	if err := os.WriteFile(file, []byte("apps\n# comment\n\n\tdefault\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	namespaces, err := readNamespacesFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(namespaces, []string{"apps", "default"}) {
+		t.Errorf("unexpected namespaces: %v", namespaces)
+	}
+
+	if _, err := readNamespacesFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     map[string]struct{}
+		expected []string
+	}{
+		{"empty", map[string]struct{}{}, []string{}},
+		{"sorted", toSet([]string{"c", "a", "b"}), []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if keys := sortedKeys(tt.keys); !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, keys)
+			}
+		})
+	}
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+
+	return set
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"sort"
+	"strings"
+
+	myK8s "github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/projectdiscovery/gologger"
+	v1 "k8s.io/api/authorization/v1"
+)
+
+// nonResources returns the non-resource URLs to be analyzed
+//
+// The list is composed by the paths served by the `/` discovery endpoint, the well-known
+// sensitive paths and the paths provided by the user
+func (r *Runner) nonResources() []*Resource {
+	urls := make(map[string]struct{})
+
+	discovered, err := r.discoverer.NonResourceURLs(r.Context)
+	if err != nil {
+		gologger.Warning().Msgf("could not discover non-resource urls. error: %s\n", err)
+	}
+
+	for _, url := range discovered {
+		urls[url] = struct{}{}
+	}
+
+	for _, url := range myK8s.SensitiveNonResourceURLs {
+		urls[url] = struct{}{}
+	}
+
+	for _, url := range r.NonResourceURLs {
+		if !strings.HasPrefix(url, "/") {
+			url = "/" + url
+		}
+		urls[url] = struct{}{}
+	}
+
+	resources := make([]*Resource, 0, len(urls))
+	for url := range urls {
+		resources = append(resources, &Resource{NonResourceURL: url})
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].NonResourceURL < resources[j].NonResourceURL
+	})
+
+	return resources
+}
+
+// settleNonResource decides if a verb is allowed in a non-resource URL only using the rules of the review
+//
+// Non-resource rules are only granted by ClusterRoleBindings, so a matching rule always settles the verb
+func (rr *rulesReview) settleNonResource(verb string, resource *Resource) (review *v1.SelfSubjectAccessReview, settled bool) {
+	for _, rule := range rr.status.NonResourceRules {
+		if ruleValueMatches(rule.Verbs, verb) && ruleNonResourceURLMatches(rule.NonResourceURLs, resource.NonResourceURL) {
+			return rr.review(verb, resource, true), true
+		}
+	}
+
+	if rr.status.Incomplete {
+		return nil, false
+	}
+
+	return rr.review(verb, resource, false), true
+}
+
+// ruleNonResourceURLMatches checks if a rule list of non-resource URLs contains the path
+//
+// A rule URL ending with `*` matches every path with the same prefix
+func ruleNonResourceURLMatches(ruleURLs []string, path string) bool {
+	for _, ruleURL := range ruleURLs {
+		if ruleURL == "*" || ruleURL == path {
+			return true
+		}
+
+		if strings.HasSuffix(ruleURL, "*") && strings.HasPrefix(path, strings.TrimSuffix(ruleURL, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,201 @@
+package runner
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PermissionKey identifies a resource, sub-resource or non-resource URL in a namespace
+//
+// Cluster-wide resources and non-resource URLs have an empty Namespace
+type PermissionKey struct {
+	Group          string
+	Version        string
+	Resource       string
+	SubResource    string
+	NonResourceURL string
+	Namespace      string
+}
+
+// KeyFromResult returns the PermissionKey of the resource analyzed in a Result
+func KeyFromResult(result *Result) PermissionKey {
+	return PermissionKey{
+		Group:          result.Resource.GroupName,
+		Version:        result.Resource.GroupVersion,
+		Resource:       result.Resource.Name,
+		SubResource:    result.Resource.SubResource,
+		NonResourceURL: result.Resource.NonResourceURL,
+		Namespace:      result.Namespace,
+	}
+}
+
+// GroupVersionResource returns the GroupVersionResource of the key
+//
+// The sub-resource, when present, is appended to the resource, like `pods/exec`
+func (k PermissionKey) GroupVersionResource() schema.GroupVersionResource {
+	resource := k.Resource
+	if k.SubResource != "" {
+		resource += "/" + k.SubResource
+	}
+
+	return schema.GroupVersionResource{Group: k.Group, Version: k.Version, Resource: resource}
+}
+
+// String returns the string representation of a PermissionKey
+func (k PermissionKey) String() string {
+	if k.NonResourceURL != "" {
+		return k.NonResourceURL
+	}
+
+	sb := &strings.Builder{}
+
+	sb.WriteString(k.Resource)
+
+	if k.Group != "" {
+		sb.WriteString("." + k.Group)
+	}
+
+	sb.WriteString("/" + k.Version)
+
+	if k.SubResource != "" {
+		sb.WriteString("/" + k.SubResource)
+	}
+
+	if k.Namespace != "" {
+		sb.WriteString(" (" + k.Namespace + ")")
+	}
+
+	return sb.String()
+}
+
+// PermissionSet holds the allowed verbs of every resource, sub-resource and non-resource URL
+//
+// It is safe for concurrent use
+type PermissionSet struct {
+	mutex       sync.RWMutex
+	permissions map[PermissionKey]map[string]struct{}
+}
+
+// NewPermissionSet creates a PermissionSet holding the allowed verbs of the results
+func NewPermissionSet(results ...*Result) *PermissionSet {
+	ps := &PermissionSet{
+		permissions: make(map[PermissionKey]map[string]struct{}),
+	}
+
+	for _, result := range results {
+		ps.Add(result)
+	}
+
+	return ps
+}
+
+// Add stores the allowed verbs of a Result
+func (ps *PermissionSet) Add(result *Result) {
+	if len(result.AllowedVerbs) == 0 {
+		return
+	}
+
+	ps.AddVerbs(KeyFromResult(result), result.AllowedVerbs...)
+}
+
+// AddVerbs stores allowed verbs for a key
+func (ps *PermissionSet) AddVerbs(key PermissionKey, verbs ...string) {
+	if len(verbs) == 0 {
+		return
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	keyVerbs, ok := ps.permissions[key]
+	if !ok {
+		keyVerbs = make(map[string]struct{}, len(verbs))
+		ps.permissions[key] = keyVerbs
+	}
+
+	for _, verb := range verbs {
+		keyVerbs[verb] = struct{}{}
+	}
+}
+
+// Can checks if a verb is allowed in a resource of a namespace
+//
+// The resource of gvr may contain a sub-resource, like `pods/exec`. An empty version matches any
+// version of the resource. Cluster-wide resources are allowed in any namespace
+func (ps *PermissionSet) Can(verb string, gvr schema.GroupVersionResource, ns string) bool {
+	resource, subResource, _ := strings.Cut(gvr.Resource, "/")
+
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	for key, verbs := range ps.permissions {
+		if key.NonResourceURL != "" ||
+			key.Group != gvr.Group ||
+			key.Resource != resource ||
+			key.SubResource != subResource ||
+			(gvr.Version != "" && key.Version != gvr.Version) ||
+			(key.Namespace != "" && key.Namespace != ns) {
+			continue
+		}
+
+		if _, ok := verbs[verb]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanAccessURL checks if a verb is allowed in a non-resource URL
+func (ps *PermissionSet) CanAccessURL(verb, url string) bool {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	_, ok := ps.permissions[PermissionKey{NonResourceURL: url}][verb]
+	return ok
+}
+
+// Resources returns the keys with at least one allowed verb, sorted by their string representation
+func (ps *PermissionSet) Resources() []PermissionKey {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	keys := make([]PermissionKey, 0, len(ps.permissions))
+	for key := range ps.permissions {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
+
+// Verbs returns the sorted allowed verbs of a key
+func (ps *PermissionSet) Verbs(key PermissionKey) []string {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	verbs := make([]string, 0, len(ps.permissions[key]))
+	for verb := range ps.permissions[key] {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+
+	return verbs
+}
+
+// Len returns the number of keys with at least one allowed verb
+func (ps *PermissionSet) Len() int {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	return len(ps.permissions)
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPermissionSet(t *testing.T) {
+	ps := NewPermissionSet(
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true},
+			Namespace:    "default",
+			AllowedVerbs: []string{"get", "list"},
+		},
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "pods", SubResource: "exec", Namespaced: true},
+			Namespace:    "default",
+			AllowedVerbs: []string{"create"},
+		},
+		&Result{
+			Resource:     &Resource{GroupName: "apps", GroupVersion: "v1", Name: "deployments", Namespaced: true},
+			Namespace:    "kube-system",
+			AllowedVerbs: []string{"patch"},
+		},
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "nodes"},
+			AllowedVerbs: []string{"list"},
+		},
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "secrets", Namespaced: true},
+			Namespace:    "default",
+			AllowedVerbs: []string{},
+		},
+		&Result{
+			Resource:     &Resource{NonResourceURL: "/metrics"},
+			AllowedVerbs: []string{"get"},
+		},
+	)
+
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	podsExec := schema.GroupVersionResource{Resource: "pods/exec"}
+	deployments := schema.GroupVersionResource{Group: "apps", Resource: "deployments"}
+	nodes := schema.GroupVersionResource{Resource: "nodes"}
+	secrets := schema.GroupVersionResource{Resource: "secrets"}
+
+	tests := []struct {
+		name     string
+		verb     string
+		gvr      schema.GroupVersionResource
+		ns       string
+		expected bool
+	}{
+		{"allowed verb", "get", pods, "default", true},
+		{"denied verb", "delete", pods, "default", false},
+		{"other namespace", "get", pods, "kube-system", false},
+		{"sub-resource", "create", podsExec, "default", true},
+		{"sub-resource does not leak to resource", "create", pods, "default", false},
+		{"group is part of the key", "patch", schema.GroupVersionResource{Resource: "deployments"}, "kube-system", false},
+		{"any version", "patch", deployments, "kube-system", true},
+		{"other version", "patch", schema.GroupVersionResource{Group: "apps", Version: "v1beta1", Resource: "deployments"}, "kube-system", false},
+		{"cluster-wide resource in any namespace", "list", nodes, "default", true},
+		{"no allowed verbs", "get", secrets, "default", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ps.Can(tt.verb, tt.gvr, tt.ns); got != tt.expected {
+				t.Fatalf("expected Can(%s, %s, %s) = %v, got %v", tt.verb, tt.gvr, tt.ns, tt.expected, got)
+			}
+		})
+	}
+
+	if !ps.CanAccessURL("get", "/metrics") || ps.CanAccessURL("post", "/metrics") {
+		t.Fatal("unexpected non-resource url permissions")
+	}
+
+	if ps.Len() != 5 || len(ps.Resources()) != 5 {
+		t.Fatalf("expected 5 keys with allowed verbs, got %d", ps.Len())
+	}
+
+	podsKey := PermissionKey{Version: "v1", Resource: "pods", Namespace: "default"}
+	if verbs := ps.Verbs(podsKey); !reflect.DeepEqual(verbs, []string{"get", "list"}) {
+		t.Fatalf("unexpected verbs for pods: %v", verbs)
+	}
+}
@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ing-bank/kal/pkg/policy"
+	"github.com/logrusorgru/aurora/v4"
+)
+
+// PolicyPermissions returns the allowed verbs of a PermissionSet as the permissions checked by a policy.Policy
+func PolicyPermissions(ps *PermissionSet) []*policy.Permission {
+	keys := ps.Resources()
+
+	permissions := make([]*policy.Permission, 0, len(keys))
+	for _, key := range keys {
+		permissions = append(permissions, &policy.Permission{
+			Group:          key.Group,
+			Resource:       key.GroupVersionResource().Resource,
+			NonResourceURL: key.NonResourceURL,
+			Namespace:      key.Namespace,
+			Verbs:          ps.Verbs(key),
+		})
+	}
+
+	return permissions
+}
+
+// PolicyResources returns the resources of the results, with their sub-resources, as the resources
+// found in the cluster by a policy check
+//
+// Without `-all`, only the resources with allowed verbs are in the results
+func PolicyResources(results []*Result) []policy.Resource {
+	seen := make(map[policy.Resource]struct{})
+	resources := make([]policy.Resource, 0)
+
+	for _, result := range results {
+		if result.Resource.IsNonResource() {
+			continue
+		}
+
+		resource := policy.Resource{
+			Group:    result.Resource.GroupName,
+			Resource: KeyFromResult(result).GroupVersionResource().Resource,
+		}
+		if _, ok := seen[resource]; !ok {
+			seen[resource] = struct{}{}
+			resources = append(resources, resource)
+		}
+	}
+
+	return resources
+}
+
+// CheckPolicy sets the policy violations of every target of a report
+//
+// A target matches the identities of the policy by its username, label or kubeconfig context.
+// With checkMissing, the expected verbs not allowed are violations too. A target that could not
+// be analyzed is a violation, so the check does not pass with unknown permissions
+func CheckPolicy(report *Report, p *policy.Policy, checkMissing bool) {
+	for _, targetReport := range report.Targets {
+		if targetReport.Error != "" || targetReport.Target == nil {
+			targetReport.PolicyViolations = []*policy.Violation{{Kind: policy.NotAnalyzed}}
+			continue
+		}
+
+		names := []string{targetReport.Label, targetReport.Context}
+		if targetReport.Identity != nil {
+			names = append(names, targetReport.Identity.Username)
+		}
+
+		permissions := PolicyPermissions(NewPermissionSet(targetReport.Results...))
+		targetReport.PolicyViolations = p.Check(names, permissions, PolicyResources(targetReport.Results), checkMissing)
+	}
+}
+
+// PolicyViolated checks if a target of the report has a policy violation
+func PolicyViolated(report *Report) bool {
+	for _, targetReport := range report.Targets {
+		if len(targetReport.PolicyViolations) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WritePolicyViolations writes the policy violations of every target of a report, a nil aurora disables colors
+//
+// With showTarget, every violation is prefixed with the name of its target
+func WritePolicyViolations(writer io.Writer, report *Report, au *aurora.Aurora, showTarget bool) error {
+	if au == nil {
+		au = aurora.New(aurora.WithColors(false))
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString("POLICY VIOLATIONS\n")
+
+	for _, targetReport := range report.Targets {
+		for _, violation := range targetReport.PolicyViolations {
+			if showTarget {
+				builder.WriteRune('[')
+				builder.WriteString(au.Cyan(targetReport.Name()).String())
+				builder.WriteString("] ")
+			}
+
+			switch violation.Kind {
+			case policy.UnknownIdentity:
+				identity := "unknown"
+				if targetReport.Identity != nil {
+					identity = targetReport.Identity.Username
+				}
+				builder.WriteString(au.Red("no identity of the policy matches " + identity).String())
+				builder.WriteRune('\n')
+				continue
+			case policy.NotAnalyzed:
+				builder.WriteString(au.Red("could not be analyzed: " + targetReport.Error).String())
+				builder.WriteRune('\n')
+				continue
+			case policy.Exceeded:
+				builder.WriteString(au.Red(string(violation.Kind)).String())
+			default:
+				builder.WriteString(au.Yellow(string(violation.Kind)).String())
+			}
+
+			scope := violation.Namespace
+			switch {
+			case scope != "":
+			case strings.HasPrefix(violation.Resource, "/"):
+				scope = "NON_RESOURCE"
+			case violation.Kind == policy.Missing:
+				// the missing verbs of a rule without namespaces are expected in any namespace
+				scope = "ANY_NAMESPACE"
+			default:
+				scope = "CLUSTER_WIDE"
+			}
+
+			builder.WriteString(" " + violation.Resource)
+			if len(violation.APIGroups) > 0 {
+				groups := make([]string, 0, len(violation.APIGroups))
+				for _, group := range violation.APIGroups {
+					if group == "" {
+						group = "core"
+					}
+					groups = append(groups, group)
+				}
+				builder.WriteString(" (api groups " + strings.Join(groups, ", ") + ")")
+			}
+			builder.WriteString(" [" + au.Green(strings.Join(violation.Verbs, ",")).String() + "]")
+			builder.WriteString(" [" + au.Blue(scope).String() + "]\n")
+		}
+	}
+
+	if !PolicyViolated(report) {
+		builder.WriteString("the allowed permissions match the policy\n")
+	}
+
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
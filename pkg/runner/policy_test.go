@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/ing-bank/kal/pkg/policy"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	p := &policy.Policy{Identities: []*policy.Identity{{
+		Name:  "ci",
+		Rules: []*policy.Rule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}},
+	}}}
+
+	report := &Report{Targets: []*TargetReport{
+		{
+			Target: &Target{Label: "ci"},
+			Results: []*Result{{
+				Resource:     &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true},
+				Namespace:    "default",
+				AllowedVerbs: []string{"get", "list"},
+			}},
+		},
+		{Target: &Target{Label: "ci", Context: "expired"}, Error: "Unauthorized"},
+	}}
+
+	CheckPolicy(report, p, true)
+
+	if len(report.Targets[0].PolicyViolations) != 0 {
+		t.Errorf("unexpected violations %+v", report.Targets[0].PolicyViolations)
+	}
+
+	if violations := report.Targets[1].PolicyViolations; len(violations) != 1 || violations[0].Kind != policy.NotAnalyzed {
+		t.Errorf("expected the target that could not be analyzed to violate the policy, got %+v", violations)
+	}
+
+	if !PolicyViolated(report) {
+		t.Errorf("expected the report to violate the policy")
+	}
+}
@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// rbacNameInvalid matches the characters not allowed in the name of a Role
+var rbacNameInvalid = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// RBACObjects returns the Roles, one per namespace, and the ClusterRole granting the allowed verbs of a PermissionSet
+//
+// Namespaced resources go into the Role of their namespace, cluster-wide resources and non-resource
+// URLs into the ClusterRole. The rules are merged by API group and verb set, with the sub-resources
+// appended to the resources, like `pods/exec`. The ClusterRole is nil without cluster-wide permissions
+func RBACObjects(ps *PermissionSet, name string) ([]*rbacv1.Role, *rbacv1.ClusterRole) {
+	namespaced := make(map[string][]PermissionKey)
+	clusterWide := make([]PermissionKey, 0)
+
+	for _, key := range ps.Resources() {
+		if key.Namespace == "" {
+			clusterWide = append(clusterWide, key)
+		} else {
+			namespaced[key.Namespace] = append(namespaced[key.Namespace], key)
+		}
+	}
+
+	namespaces := make(map[string]struct{}, len(namespaced))
+	for ns := range namespaced {
+		namespaces[ns] = struct{}{}
+	}
+
+	roles := make([]*rbacv1.Role, 0, len(namespaced))
+	for _, ns := range sortedKeys(namespaces) {
+		roles = append(roles, &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Rules:      policyRules(ps, namespaced[ns]),
+		})
+	}
+
+	var clusterRole *rbacv1.ClusterRole
+	if len(clusterWide) > 0 {
+		clusterRole = &rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Rules:      policyRules(ps, clusterWide),
+		}
+	}
+
+	return roles, clusterRole
+}
+
+// policyRules merges the allowed verbs of the keys into rules, by API group and verb set
+//
+// Non-resource URLs are merged by verb set in their own rules
+func policyRules(ps *PermissionSet, keys []PermissionKey) []rbacv1.PolicyRule {
+	type ruleKey struct {
+		group       string
+		verbs       string
+		nonResource bool
+	}
+
+	items := make(map[ruleKey]map[string]struct{})
+	for _, key := range keys {
+		verbs := ps.Verbs(key)
+		if len(verbs) == 0 {
+			continue
+		}
+
+		rk := ruleKey{group: key.Group, verbs: strings.Join(verbs, ","), nonResource: key.NonResourceURL != ""}
+
+		item := key.NonResourceURL
+		if !rk.nonResource {
+			item = key.GroupVersionResource().Resource
+		}
+
+		if _, ok := items[rk]; !ok {
+			items[rk] = make(map[string]struct{})
+		}
+		items[rk][item] = struct{}{}
+	}
+
+	ruleKeys := make([]ruleKey, 0, len(items))
+	for rk := range items {
+		ruleKeys = append(ruleKeys, rk)
+	}
+	sort.Slice(ruleKeys, func(i, j int) bool {
+		if ruleKeys[i].nonResource != ruleKeys[j].nonResource {
+			return !ruleKeys[i].nonResource
+		}
+		if ruleKeys[i].group != ruleKeys[j].group {
+			return ruleKeys[i].group < ruleKeys[j].group
+		}
+		return ruleKeys[i].verbs < ruleKeys[j].verbs
+	})
+
+	rules := make([]rbacv1.PolicyRule, 0, len(ruleKeys))
+	for _, rk := range ruleKeys {
+		rule := rbacv1.PolicyRule{Verbs: strings.Split(rk.verbs, ",")}
+		if rk.nonResource {
+			rule.NonResourceURLs = sortedKeys(items[rk])
+		} else {
+			rule.APIGroups = []string{rk.group}
+			rule.Resources = sortedKeys(items[rk])
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// RBACName returns a valid Role name for a target, like `kal-ci-deployer`
+//
+// It uses the label or the kubeconfig context of the target, then the username of its identity
+func RBACName(target *Target) string {
+	name := ""
+	switch {
+	case target == nil:
+	case target.Label != "":
+		name = target.Label
+	case target.Context != "":
+		name = target.Context
+	case target.Identity != nil:
+		name = target.Identity.Username
+	}
+
+	name = strings.Trim(rbacNameInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+	if name == "" {
+		return "kal"
+	}
+
+	name = "kal-" + name
+	if len(name) > 253 {
+		name = strings.TrimRight(name[:253], "-.")
+	}
+
+	return name
+}
+
+// WriteRBAC writes the Roles and ClusterRoles granting the allowed verbs of every analyzed target
+// of a report, as a multi-document YAML
+func WriteRBAC(writer io.Writer, report *Report) error {
+	documents := make([]any, 0)
+	for _, targetReport := range report.Targets {
+		if targetReport.Error != "" {
+			continue
+		}
+
+		roles, clusterRole := RBACObjects(NewPermissionSet(targetReport.Results...), RBACName(targetReport.Target))
+		if clusterRole != nil {
+			documents = append(documents, clusterRole)
+		}
+		for _, role := range roles {
+			documents = append(documents, role)
+		}
+	}
+
+	for i, document := range documents {
+		data, err := yaml.Marshal(document)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			if _, err := io.WriteString(writer, "---\n"); err != nil {
+				return err
+			}
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
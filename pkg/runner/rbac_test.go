@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestRBACObjects(t *testing.T) {
+	ps := NewPermissionSet(
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true},
+			Namespace:    "default",
+			AllowedVerbs: []string{"get", "list"},
+		},
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "configmaps", Namespaced: true},
+			Namespace:    "default",
+			AllowedVerbs: []string{"list", "get"},
+		},
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "pods", SubResource: "exec", Namespaced: true},
+			Namespace:    "default",
+			AllowedVerbs: []string{"create"},
+		},
+		&Result{
+			Resource:     &Resource{GroupName: "apps", GroupVersion: "v1", Name: "deployments", Namespaced: true},
+			Namespace:    "kube-system",
+			AllowedVerbs: []string{"patch"},
+		},
+		&Result{
+			Resource:     &Resource{GroupVersion: "v1", Name: "nodes"},
+			AllowedVerbs: []string{"list"},
+		},
+		&Result{
+			Resource:     &Resource{NonResourceURL: "/metrics"},
+			AllowedVerbs: []string{"get"},
+		},
+	)
+
+	roles, clusterRole := RBACObjects(ps, "kal-test")
+
+	expectedRoles := map[string][]rbacv1.PolicyRule{
+		"default": {
+			{APIGroups: []string{""}, Resources: []string{"pods/exec"}, Verbs: []string{"create"}},
+			{APIGroups: []string{""}, Resources: []string{"configmaps", "pods"}, Verbs: []string{"get", "list"}},
+		},
+		"kube-system": {
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"patch"}},
+		},
+	}
+
+	if len(roles) != len(expectedRoles) {
+		t.Fatalf("expected %d roles, got %d", len(expectedRoles), len(roles))
+	}
+
+	for _, role := range roles {
+		if role.Name != "kal-test" || role.Kind != "Role" || role.APIVersion != "rbac.authorization.k8s.io/v1" {
+			t.Errorf("unexpected role metadata %v %v", role.TypeMeta, role.ObjectMeta)
+		}
+
+		if !reflect.DeepEqual(role.Rules, expectedRoles[role.Namespace]) {
+			t.Errorf("unexpected rules in namespace %s: %+v", role.Namespace, role.Rules)
+		}
+	}
+
+	expectedClusterRules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+	}
+
+	if clusterRole == nil || !reflect.DeepEqual(clusterRole.Rules, expectedClusterRules) {
+		t.Errorf("unexpected cluster role %+v", clusterRole)
+	}
+}
+
+func TestRBACName(t *testing.T) {
+	tests := []struct {
+		target   *Target
+		expected string
+	}{
+		{&Target{Label: "CI Deployer"}, "kal-ci-deployer"},
+		{&Target{Context: "admin@prod", Identity: &Identity{Username: "admin"}}, "kal-admin-prod"},
+		{&Target{Identity: &Identity{Username: "system:serviceaccount:default:viewer"}}, "kal-system-serviceaccount-default-viewer"},
+		{&Target{ServerURL: "https://cluster:6443"}, "kal"},
+	}
+
+	for _, tt := range tests {
+		if name := RBACName(tt.target); name != tt.expected {
+			t.Errorf("expected name %s, got %s", tt.expected, name)
+		}
+	}
+}
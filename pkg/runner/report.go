@@ -0,0 +1,304 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	myK8s "github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/ing-bank/kal/pkg/policy"
+	"github.com/ing-bank/kal/pkg/risk"
+	v1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Identity is the user or service account whose permissions are listed
+type Identity struct {
+	Username string              `json:"username"`
+	UID      string              `json:"uid,omitempty"`
+	Groups   []string            `json:"groups,omitempty"`
+	Extra    map[string][]string `json:"extra,omitempty"`
+	// Source is how the identity was found, like IdentitySourceSelfSubjectReview
+	Source string `json:"source,omitempty"`
+}
+
+// identityFromImpersonation returns the impersonated identity, or nil when there is no impersonation
+func identityFromImpersonation(impersonation rest.ImpersonationConfig) *Identity {
+	if impersonation.UserName == "" {
+		return nil
+	}
+
+	return &Identity{
+		Username: impersonation.UserName,
+		UID:      impersonation.UID,
+		Groups:   impersonation.Groups,
+		Extra:    impersonation.Extra,
+		Source:   IdentitySourceImpersonation,
+	}
+}
+
+// Report is the aggregated document holding the results of a KAL execution
+type Report struct {
+	KALVersion string          `json:"kalVersion"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Targets    []*TargetReport `json:"targets"`
+	// Summary summarizes the targets, when many authentications are analyzed
+	Summary []*TargetSummary `json:"summary,omitempty"`
+}
+
+// Target describes the cluster and the authentication analyzed by a Runner
+type Target struct {
+	// Label names the authentication, like the label of a token in a tokens file
+	Label     string `json:"label,omitempty"`
+	ServerURL string `json:"serverURL"`
+	// Context and Cluster are the kubeconfig context and cluster names, when the kubeconfig is used
+	Context    string    `json:"context,omitempty"`
+	Cluster    string    `json:"cluster,omitempty"`
+	Identity   *Identity `json:"identity,omitempty"`
+	Namespaces []string  `json:"namespaces"`
+	// AllNamespaces is set when the Namespaces were listed from the cluster
+	AllNamespaces bool `json:"allNamespaces,omitempty"`
+	// AllResults is set when the results without allowed verbs are reported too, with `-all`
+	AllResults bool `json:"allResults,omitempty"`
+	// TokenVerification is the verification of the signature of the token, when it is enabled
+	TokenVerification *myK8s.TokenVerification `json:"tokenVerification,omitempty"`
+}
+
+// Name returns the name of the Target, using its label, kubeconfig context or server url
+func (t *Target) Name() string {
+	switch {
+	case t.Label != "":
+		return t.Label
+	case t.Context != "":
+		return t.Context
+	default:
+		return t.ServerURL
+	}
+}
+
+// TargetReport holds the results of the analysis of a Target
+type TargetReport struct {
+	*Target
+	// Error is the reason the Target could not be analyzed
+	Error   string    `json:"error,omitempty"`
+	Results []*Result `json:"results"`
+	// Findings are the risks of the allowed permissions, the most severe first
+	Findings []*risk.Finding `json:"findings,omitempty"`
+	// EscalationGraph chains the allowed permissions into privilege escalation paths
+	EscalationGraph *risk.Graph `json:"escalationGraph,omitempty"`
+	// PolicyViolations are the differences between the allowed permissions and the expected ones
+	PolicyViolations []*policy.Violation `json:"policyViolations,omitempty"`
+}
+
+// VerbResult is the result of the access review of a verb
+type VerbResult struct {
+	Verb            string `json:"verb"`
+	Allowed         bool   `json:"allowed"`
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+}
+
+// jsonResult is the JSON representation of a Result
+type jsonResult struct {
+	Label          string        `json:"label,omitempty"`
+	Identity       *Identity     `json:"identity,omitempty"`
+	Context        string        `json:"context,omitempty"`
+	Cluster        string        `json:"cluster,omitempty"`
+	Resource       string        `json:"resource,omitempty"`
+	Group          string        `json:"group"`
+	Version        string        `json:"version,omitempty"`
+	SubResource    string        `json:"subresource,omitempty"`
+	NonResourceURL string        `json:"nonResourceURL,omitempty"`
+	Namespace      string        `json:"namespace,omitempty"`
+	Namespaced     bool          `json:"namespaced"`
+	AllowedVerbs   []string      `json:"allowedVerbs"`
+	DeniedVerbs    []string      `json:"deniedVerbs"`
+	Verbs          []*VerbResult `json:"verbs"`
+	AllResults     bool          `json:"allResults,omitempty"`
+}
+
+// VerbResults returns the result of the access review of every verb tested in the resource
+func (r *Result) VerbResults() []*VerbResult {
+	verbResults := make([]*VerbResult, 0, len(r.SelfSubjectAccessReviewResults))
+	for _, review := range r.SelfSubjectAccessReviewResults {
+		verbResults = append(verbResults, &VerbResult{
+			Verb:            reviewVerb(review),
+			Allowed:         review.Status.Allowed,
+			Denied:          review.Status.Denied,
+			Reason:          review.Status.Reason,
+			EvaluationError: review.Status.EvaluationError,
+		})
+	}
+
+	return verbResults
+}
+
+// MarshalJSON returns the JSON representation of a Result
+func (r *Result) MarshalJSON() ([]byte, error) {
+	jr := &jsonResult{
+		Resource:       r.Resource.Name,
+		Group:          r.Resource.GroupName,
+		Version:        r.Resource.GroupVersion,
+		SubResource:    r.Resource.SubResource,
+		NonResourceURL: r.Resource.NonResourceURL,
+		Namespace:      r.Namespace,
+		Namespaced:     r.Resource.Namespaced,
+		AllowedVerbs:   r.AllowedVerbs,
+		DeniedVerbs:    r.DeniedVerbs,
+		Verbs:          r.VerbResults(),
+	}
+
+	if r.Target != nil {
+		jr.Label = r.Target.Label
+		jr.Identity = r.Target.Identity
+		jr.Context = r.Target.Context
+		jr.Cluster = r.Target.Cluster
+		jr.AllResults = r.Target.AllResults
+	}
+
+	return json.Marshal(jr)
+}
+
+// UnmarshalJSON reads a Result from its JSON representation
+//
+// The access reviews are rebuilt from the verbs of the document, and the target holds only the
+// label, identity, context, cluster and `-all` flag of the result
+func (r *Result) UnmarshalJSON(data []byte) error {
+	jr := &jsonResult{}
+	if err := json.Unmarshal(data, jr); err != nil {
+		return err
+	}
+
+	r.Resource = &Resource{
+		Name:           jr.Resource,
+		GroupName:      jr.Group,
+		GroupVersion:   jr.Version,
+		SubResource:    jr.SubResource,
+		NonResourceURL: jr.NonResourceURL,
+		Namespaced:     jr.Namespaced,
+	}
+	r.Namespace = jr.Namespace
+	r.AllowedVerbs = jr.AllowedVerbs
+	r.DeniedVerbs = jr.DeniedVerbs
+
+	r.SelfSubjectAccessReviewResults = make([]*v1.SelfSubjectAccessReview, 0, len(jr.Verbs))
+	for _, verbResult := range jr.Verbs {
+		r.SelfSubjectAccessReviewResults = append(r.SelfSubjectAccessReviewResults, verbResult.review(r))
+	}
+
+	if jr.Label != "" || jr.Identity != nil || jr.Context != "" || jr.Cluster != "" || jr.AllResults {
+		r.Target = &Target{Label: jr.Label, Identity: jr.Identity, Context: jr.Context, Cluster: jr.Cluster, AllResults: jr.AllResults}
+	}
+
+	return nil
+}
+
+// review returns the access review of the verb in the resource of a result
+func (vr *VerbResult) review(r *Result) *v1.SelfSubjectAccessReview {
+	review := &v1.SelfSubjectAccessReview{
+		Status: v1.SubjectAccessReviewStatus{
+			Allowed:         vr.Allowed,
+			Denied:          vr.Denied,
+			Reason:          vr.Reason,
+			EvaluationError: vr.EvaluationError,
+		},
+	}
+
+	if r.Resource.IsNonResource() {
+		review.Spec.NonResourceAttributes = &v1.NonResourceAttributes{Path: r.Resource.NonResourceURL, Verb: vr.Verb}
+	} else {
+		review.Spec.ResourceAttributes = &v1.ResourceAttributes{
+			Namespace:   r.Namespace,
+			Verb:        vr.Verb,
+			Group:       r.Resource.GroupName,
+			Version:     r.Resource.GroupVersion,
+			Resource:    r.Resource.Name,
+			Subresource: r.Resource.SubResource,
+		}
+	}
+
+	return review
+}
+
+// ReadReport reads a report written by any JSON output format
+//
+// A `-json-aggregate` document is read as is. The results of `-json` and `-json-lines` are grouped
+// in a target per label, context, cluster and identity
+func ReadReport(reader io.Reader) (*Report, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err == nil {
+		if _, ok := probe["targets"]; ok {
+			report := &Report{}
+			if err := json.Unmarshal(data, report); err != nil {
+				return nil, err
+			}
+
+			for _, targetReport := range report.Targets {
+				if targetReport.Target == nil {
+					targetReport.Target = &Target{}
+				}
+				for _, result := range targetReport.Results {
+					result.Target = targetReport.Target
+				}
+			}
+
+			return report, nil
+		}
+	}
+
+	type targetKey struct {
+		label, context, cluster, username string
+	}
+
+	sink := NewReportSink()
+	targets := make(map[targetKey]*Target)
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		result := &Result{}
+		if err := decoder.Decode(result); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read result: %w", err)
+		}
+
+		// results of the same target share the same Target, grouping them in the report
+		key := targetKey{}
+		if result.Target != nil {
+			key = targetKey{label: result.Target.Label, context: result.Target.Context, cluster: result.Target.Cluster}
+			if result.Target.Identity != nil {
+				key.username = result.Target.Identity.Username
+			}
+		}
+
+		if _, ok := targets[key]; !ok {
+			targets[key] = result.Target
+		}
+		result.Target = targets[key]
+
+		_ = sink.Write(result)
+	}
+
+	return sink.Report(), nil
+}
+
+// reviewVerb returns the verb evaluated in an access review
+func reviewVerb(review *v1.SelfSubjectAccessReview) string {
+	if review.Spec.NonResourceAttributes != nil {
+		return review.Spec.NonResourceAttributes.Verb
+	}
+
+	if review.Spec.ResourceAttributes != nil {
+		return review.Spec.ResourceAttributes.Verb
+	}
+
+	return ""
+}
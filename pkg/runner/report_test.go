@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/authorization/v1"
+)
+
+// reviewedResult returns a result with an access review per allowed and denied verb
+func reviewedResult(target *Target, resource *Resource, ns string, allowed, denied []string) *Result {
+	result := &Result{
+		Target:       target,
+		Resource:     resource,
+		Namespace:    ns,
+		AllowedVerbs: allowed,
+		DeniedVerbs:  denied,
+	}
+
+	for _, verb := range allowed {
+		result.SelfSubjectAccessReviewResults = append(result.SelfSubjectAccessReviewResults, verbReview(result, verb, true))
+	}
+	for _, verb := range denied {
+		result.SelfSubjectAccessReviewResults = append(result.SelfSubjectAccessReviewResults, verbReview(result, verb, false))
+	}
+
+	return result
+}
+
+// verbReview returns the access review of a verb of a result
+func verbReview(result *Result, verb string, allowed bool) *v1.SelfSubjectAccessReview {
+	verbResult := &VerbResult{Verb: verb, Allowed: allowed}
+	if allowed {
+		verbResult.Reason = "allowed by test"
+	}
+
+	return verbResult.review(result)
+}
+
+func TestReadReport(t *testing.T) {
+	target := &Target{
+		Label:      "ci",
+		Context:    "admin@prod",
+		Cluster:    "prod",
+		Identity:   &Identity{Username: "system:serviceaccount:ci:deployer"},
+		AllResults: true,
+	}
+
+	results := []*Result{
+		reviewedResult(target, &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true}, "apps", []string{"get", "list"}, []string{"delete"}),
+		reviewedResult(target, &Resource{GroupName: "apps", GroupVersion: "v1", Name: "deployments", SubResource: "scale", Namespaced: true}, "apps", []string{"update"}, []string{"get"}),
+		reviewedResult(target, &Resource{GroupVersion: "v1", Name: "nodes"}, "", []string{}, []string{"list"}),
+		reviewedResult(target, &Resource{NonResourceURL: "/healthz"}, "", []string{"get"}, []string{"post"}),
+	}
+
+	tests := []struct {
+		name  string
+		write func(writer io.Writer) error
+	}{
+		{"json", func(writer io.Writer) error { return writeResults(NewJSONSink(writer), results) }},
+		{"json lines", func(writer io.Writer) error { return writeResults(NewJSONLinesSink(writer), results) }},
+		{"json aggregate", func(writer io.Writer) error {
+			sink := NewReportSink()
+			if err := writeResults(sink, results); err != nil {
+				return err
+			}
+			return sink.WriteJSON(writer)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			if err := tt.write(buffer); err != nil {
+				t.Fatalf("could not write the results: %s", err)
+			}
+
+			report, err := ReadReport(buffer)
+			if err != nil {
+				t.Fatalf("could not read the report: %s", err)
+			}
+
+			if len(report.Targets) != 1 {
+				t.Fatalf("expected a single target, got %d", len(report.Targets))
+			}
+
+			read := report.Targets[0]
+			if read.Label != target.Label || read.Context != target.Context || read.Cluster != target.Cluster ||
+				read.AllResults != target.AllResults || read.Identity == nil || read.Identity.Username != target.Identity.Username {
+				t.Errorf("unexpected target %+v", read.Target)
+			}
+
+			if len(read.Results) != len(results) {
+				t.Fatalf("expected %d results, got %d", len(results), len(read.Results))
+			}
+
+			for i, result := range read.Results {
+				expected := results[i]
+				if result.Target != read.Target {
+					t.Errorf("expected the result %s to share the target of the report", result.Resource)
+				}
+
+				if result.Resource.String() != expected.Resource.String() || result.Namespace != expected.Namespace {
+					t.Errorf("expected %s (%s), got %s (%s)", expected.Resource, expected.Namespace, result.Resource, result.Namespace)
+				}
+
+				if !reflect.DeepEqual(result.AllowedVerbs, expected.AllowedVerbs) || !reflect.DeepEqual(result.DeniedVerbs, expected.DeniedVerbs) {
+					t.Errorf("expected %s verbs %v/%v, got %v/%v", expected.Resource, expected.AllowedVerbs, expected.DeniedVerbs, result.AllowedVerbs, result.DeniedVerbs)
+				}
+
+				if !reflect.DeepEqual(result.VerbResults(), expected.VerbResults()) {
+					t.Errorf("unexpected access reviews of %s", expected.Resource)
+				}
+			}
+		})
+	}
+}
+
+func TestReadReportMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"not json", "pods [get,list] [default]"},
+		{"truncated result", `{"resource": "pods", "allowedVerbs": ["get"`},
+		{"invalid verbs", `{"resource": "pods", "allowedVerbs": "get"}`},
+		{"invalid targets", `{"targets": 1}`},
+		{"second result malformed", "{\"resource\": \"pods\"}\n{\"resource\": "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadReport(strings.NewReader(tt.content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func writeResults(sink ResultSink, results []*Result) error {
+	for _, result := range results {
+		if err := sink.Write(result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AccessReviewer reviews if the current authentication is allowed to execute an action
+type AccessReviewer interface {
+	ReviewAccess(ctx context.Context, spec v1.SelfSubjectAccessReviewSpec) (*v1.SelfSubjectAccessReview, error)
+}
+
+// RulesReviewer lists the rules the current authentication has in a namespace
+//
+// It is optional: when the AccessReviewer of a Runner does not implement it, the rules review
+// enumeration mode falls back to access reviews
+type RulesReviewer interface {
+	ReviewRules(ctx context.Context, namespace string) (*v1.SubjectRulesReviewStatus, error)
+}
+
+// IdentityReviewer returns the identity of the current authentication, as seen by the Kubernetes API
+//
+// It is optional: when the AccessReviewer of a Runner does not implement it, the identity is read
+// from the claims of the token
+type IdentityReviewer interface {
+	ReviewIdentity(ctx context.Context) (*Identity, error)
+}
+
+// Discoverer lists the resources and non-resource URLs served by the Kubernetes API
+type Discoverer interface {
+	Resources(ctx context.Context) ([]*Resource, error)
+	NonResourceURLs(ctx context.Context) ([]string, error)
+}
+
+// SelfSubjectReviewer is the AccessReviewer and RulesReviewer using the
+// SelfSubjectAccessReview and SelfSubjectRulesReview requests of the Kubernetes API
+type SelfSubjectReviewer struct {
+	client kubernetes.Interface
+}
+
+// NewSelfSubjectReviewer creates a SelfSubjectReviewer
+func NewSelfSubjectReviewer(client kubernetes.Interface) *SelfSubjectReviewer {
+	return &SelfSubjectReviewer{client: client}
+}
+
+// ReviewAccess sends a SelfSubjectAccessReview request
+func (ssr *SelfSubjectReviewer) ReviewAccess(ctx context.Context, spec v1.SelfSubjectAccessReviewSpec) (*v1.SelfSubjectAccessReview, error) {
+	return ssr.client.
+		AuthorizationV1().
+		SelfSubjectAccessReviews().
+		Create(
+			ctx,
+			&v1.SelfSubjectAccessReview{Spec: spec},
+			metav1.CreateOptions{},
+		)
+}
+
+// ReviewRules sends a SelfSubjectRulesReview request
+func (ssr *SelfSubjectReviewer) ReviewRules(ctx context.Context, namespace string) (*v1.SubjectRulesReviewStatus, error) {
+	srr := &v1.SelfSubjectRulesReview{
+		Spec: v1.SelfSubjectRulesReviewSpec{
+			Namespace: namespace,
+		},
+	}
+
+	rulesReviewResponse, err := ssr.client.
+		AuthorizationV1().
+		SelfSubjectRulesReviews().
+		Create(
+			ctx,
+			srr,
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rulesReviewResponse.Status, nil
+}
+
+// ReviewIdentity sends a SelfSubjectReview request
+func (ssr *SelfSubjectReviewer) ReviewIdentity(ctx context.Context) (*Identity, error) {
+	review, err := ssr.client.
+		AuthenticationV1().
+		SelfSubjectReviews().
+		Create(
+			ctx,
+			&authenticationv1.SelfSubjectReview{},
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo := review.Status.UserInfo
+	identity := &Identity{
+		Username: userInfo.Username,
+		UID:      userInfo.UID,
+		Groups:   userInfo.Groups,
+		Source:   IdentitySourceSelfSubjectReview,
+	}
+
+	for key, values := range userInfo.Extra {
+		if identity.Extra == nil {
+			identity.Extra = make(map[string][]string)
+		}
+		identity.Extra[key] = values
+	}
+
+	return identity, nil
+}
+
+// APIDiscoverer is the Discoverer using the discovery API of Kubernetes
+type APIDiscoverer struct {
+	client discovery.DiscoveryInterface
+}
+
+// NewAPIDiscoverer creates an APIDiscoverer
+func NewAPIDiscoverer(client discovery.DiscoveryInterface) *APIDiscoverer {
+	return &APIDiscoverer{client: client}
+}
+
+// Resources lists the resources and sub-resources of every api group version
+func (ad *APIDiscoverer) Resources(_ context.Context) ([]*Resource, error) {
+	apiGroupsList := make([]string, 0)
+
+	groupList, err := ad.client.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groupList.Groups {
+		for _, version := range group.Versions {
+			apiGroupsList = append(apiGroupsList, version.GroupVersion)
+		}
+	}
+
+	resources := make([]*Resource, 0)
+	for _, group := range apiGroupsList {
+		groupResources, err := ad.client.ServerResourcesForGroupVersion(group)
+
+		if err != nil {
+			gologger.Error().Msgf("could not get resources from group. error: %s\n", err)
+			continue
+		}
+
+		for _, resource := range groupResources.APIResources {
+			y := strings.Split(groupResources.GroupVersion, "/")
+
+			groupName := y[0]
+			var groupVersion string
+			if len(y) > 1 {
+				groupVersion = y[1]
+			}
+
+			resourceName := resource.Name
+			subResource := ""
+			if strings.Contains(resourceName, "/") {
+				// example: helmchartrepositories/status -> status is the sub-resource of helmchartrepositories
+
+				x := strings.Split(resourceName, "/")
+				resourceName = x[0]
+				subResource = x[1]
+			}
+
+			resourceItem := &Resource{
+				GroupName:    groupName,
+				GroupVersion: groupVersion,
+				Name:         resourceName,
+				Namespaced:   resource.Namespaced,
+				SubResource:  subResource,
+				Verbs:        resource.Verbs,
+			}
+			if groupName == "v1" {
+				resourceItem.GroupVersion = groupName
+				resourceItem.GroupName = ""
+			}
+
+			resources = append(
+				resources,
+				resourceItem,
+			)
+
+		}
+	}
+
+	return resources, nil
+}
+
+// NonResourceURLs lists the paths served by the `/` discovery endpoint
+//
+// The `/api` and `/apis` paths are ignored, as they are already analyzed as resources
+func (ad *APIDiscoverer) NonResourceURLs(ctx context.Context) ([]string, error) {
+	restClient := ad.client.RESTClient()
+	if restClient == nil {
+		return nil, errors.New("discovery client has no rest client")
+	}
+
+	body, err := restClient.
+		Get().
+		AbsPath("/").
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	rootPaths := &metav1.RootPaths{}
+	if err := json.Unmarshal(body, rootPaths); err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(rootPaths.Paths))
+	for _, path := range rootPaths.Paths {
+		if path == "/api" || path == "/apis" || strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/apis/") {
+			continue
+		}
+		urls = append(urls, path)
+	}
+
+	return urls, nil
+}
+
+// DiscoveryCache shares the resources and non-resource URLs listed by the first successful
+// discovery between many runners, like the runners of many tokens of the same cluster
+type DiscoveryCache struct {
+	mutex           sync.Mutex
+	resources       []*Resource
+	nonResourceURLs []string
+	hasURLs         bool
+}
+
+// NewDiscoveryCache creates an empty DiscoveryCache
+func NewDiscoveryCache() *DiscoveryCache {
+	return &DiscoveryCache{}
+}
+
+// Discoverer returns a Discoverer using the cache, and the discoverer when the cache is empty
+func (dc *DiscoveryCache) Discoverer(discoverer Discoverer) Discoverer {
+	return &cachedDiscoverer{cache: dc, discoverer: discoverer}
+}
+
+type cachedDiscoverer struct {
+	cache      *DiscoveryCache
+	discoverer Discoverer
+}
+
+// Resources returns the cached resources, listing them when the cache is empty
+func (cd *cachedDiscoverer) Resources(ctx context.Context) ([]*Resource, error) {
+	cd.cache.mutex.Lock()
+	defer cd.cache.mutex.Unlock()
+
+	if cd.cache.resources == nil {
+		resources, err := cd.discoverer.Resources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cd.cache.resources = resources
+	}
+
+	// the runners append to the returned slice, so each one has its own copy
+	return append([]*Resource(nil), cd.cache.resources...), nil
+}
+
+// NonResourceURLs returns the cached non-resource URLs, listing them when the cache is empty
+func (cd *cachedDiscoverer) NonResourceURLs(ctx context.Context) ([]string, error) {
+	cd.cache.mutex.Lock()
+	defer cd.cache.mutex.Unlock()
+
+	if !cd.cache.hasURLs {
+		urls, err := cd.discoverer.NonResourceURLs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cd.cache.nonResourceURLs = urls
+		cd.cache.hasURLs = true
+	}
+
+	return append([]string(nil), cd.cache.nonResourceURLs...), nil
+}
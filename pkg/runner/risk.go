@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ing-bank/kal/pkg/risk"
+	"github.com/logrusorgru/aurora/v4"
+)
+
+// RiskPermissions returns the allowed verbs of a PermissionSet as the permissions evaluated by a risk.Engine
+func RiskPermissions(ps *PermissionSet) []*risk.Permission {
+	keys := ps.Resources()
+
+	permissions := make([]*risk.Permission, 0, len(keys))
+	for _, key := range keys {
+		permissions = append(permissions, &risk.Permission{
+			Group:          key.Group,
+			Resource:       key.Resource,
+			SubResource:    key.SubResource,
+			NonResourceURL: key.NonResourceURL,
+			Namespace:      key.Namespace,
+			Verbs:          ps.Verbs(key),
+		})
+	}
+
+	return permissions
+}
+
+// EvaluateRisk sets the findings and the escalation graph of every target of a report, evaluating
+// its allowed permissions
+func EvaluateRisk(report *Report, engine *risk.Engine) {
+	for _, targetReport := range report.Targets {
+		scope := risk.Scope{
+			Namespaces:    targetReport.Namespaces,
+			AllNamespaces: targetReport.AllNamespaces,
+		}
+
+		permissions := RiskPermissions(NewPermissionSet(targetReport.Results...))
+		targetReport.Findings = engine.Evaluate(permissions, scope)
+
+		if targetReport.Error == "" {
+			targetReport.EscalationGraph = risk.BuildEscalationGraph(permissions, scope)
+		}
+	}
+}
+
+// WriteFindings writes the findings of every target of a report, a nil aurora disables colors
+//
+// With showTarget, every finding is prefixed with the name of its target
+func WriteFindings(writer io.Writer, report *Report, au *aurora.Aurora, showTarget bool) error {
+	if au == nil {
+		au = aurora.New(aurora.WithColors(false))
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString("RISK FINDINGS\n")
+
+	found := false
+	for _, targetReport := range report.Targets {
+		for _, finding := range targetReport.Findings {
+			found = true
+
+			if showTarget {
+				builder.WriteRune('[')
+				builder.WriteString(au.Cyan(targetReport.Name()).String())
+				builder.WriteString("] ")
+			}
+
+			builder.WriteRune('[')
+			builder.WriteString(severityColor(au, finding.Severity).String())
+			builder.WriteString("] ")
+			fmt.Fprintf(builder, "%s (%s)\n", finding.Title, finding.RuleID)
+
+			scopes := finding.Namespaces
+			if finding.ClusterWide {
+				scopes = append([]string{"CLUSTER_WIDE"}, scopes...)
+			}
+			fmt.Fprintf(builder, "    %s [%s]\n", strings.Join(finding.Permissions, ", "), au.Blue(strings.Join(scopes, ",")))
+		}
+	}
+
+	if !found {
+		builder.WriteString("no risky permissions found\n")
+	}
+
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// WriteEscalationPaths writes the escalation paths of every target of a report, a nil aurora disables colors
+//
+// With showTarget, every path is prefixed with the name of its target
+func WriteEscalationPaths(writer io.Writer, report *Report, au *aurora.Aurora, showTarget bool) error {
+	if au == nil {
+		au = aurora.New(aurora.WithColors(false))
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString("ESCALATION PATHS\n")
+
+	found := false
+	for _, targetReport := range report.Targets {
+		if targetReport.EscalationGraph == nil {
+			continue
+		}
+
+		labels := make(map[string]string, len(targetReport.EscalationGraph.Nodes))
+		for _, node := range targetReport.EscalationGraph.Nodes {
+			labels[node.ID] = node.Label
+		}
+
+		for _, path := range targetReport.EscalationGraph.Paths {
+			found = true
+
+			if showTarget {
+				builder.WriteRune('[')
+				builder.WriteString(au.Cyan(targetReport.Name()).String())
+				builder.WriteString("] ")
+			}
+			builder.WriteString(au.Red(path.Label).String())
+			builder.WriteRune('\n')
+
+			for i, step := range path.Steps {
+				fmt.Fprintf(builder, "    %d. %s -> %s", i+1, step.Description, labels[step.To])
+				if len(step.Permissions) > 0 {
+					fmt.Fprintf(builder, " [%s]", au.Green(strings.Join(step.Permissions, ", ")))
+				}
+				builder.WriteRune('\n')
+			}
+		}
+	}
+
+	if !found {
+		builder.WriteString("no escalation path found\n")
+	}
+
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// WriteEscalationDOT writes the escalation graph of every analyzed target of a report as a DOT digraph
+// named after the target
+func WriteEscalationDOT(writer io.Writer, report *Report) error {
+	for _, targetReport := range report.Targets {
+		if targetReport.EscalationGraph == nil {
+			continue
+		}
+
+		if err := targetReport.EscalationGraph.WriteDOT(writer, targetReport.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// targetGraph is the JSON representation of the escalation graph of a target
+type targetGraph struct {
+	Target string      `json:"target"`
+	Graph  *risk.Graph `json:"graph"`
+}
+
+// WriteEscalationJSON writes the escalation graph of every analyzed target of a report as an
+// indented JSON list
+func WriteEscalationJSON(writer io.Writer, report *Report) error {
+	graphs := make([]*targetGraph, 0, len(report.Targets))
+	for _, targetReport := range report.Targets {
+		if targetReport.EscalationGraph != nil {
+			graphs = append(graphs, &targetGraph{Target: targetReport.Name(), Graph: targetReport.EscalationGraph})
+		}
+	}
+
+	data, err := json.MarshalIndent(graphs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
+
+// severityColor returns the colored upper case severity
+func severityColor(au *aurora.Aurora, severity risk.Severity) aurora.Value {
+	name := strings.ToUpper(string(severity))
+
+	switch severity {
+	case risk.Critical:
+		return au.Bold(au.Red(name))
+	case risk.High:
+		return au.Red(name)
+	case risk.Medium:
+		return au.Yellow(name)
+	default:
+		return au.Cyan(name)
+	}
+}
@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/projectdiscovery/gologger"
+	v1 "k8s.io/api/authorization/v1"
+)
+
+const rulesReviewReason = "allowed by SelfSubjectRulesReview"
+
+// rulesReview holds the rules returned by a SelfSubjectRulesReview for a namespace
+type rulesReview struct {
+	namespace string
+	status    v1.SubjectRulesReviewStatus
+}
+
+// requestRulesReviews reviews the rules of every namespace
+//
+// Every rules review contains the rules granted by ClusterRoleBindings, so the review of the first
+// namespace is also used for cluster-wide resources and non-resource URLs
+func (r *Runner) requestRulesReviews(namespaces []string) {
+	r.rulesReviews = make(map[string]*rulesReview)
+
+	rulesReviewer, ok := r.reviewer.(RulesReviewer)
+	if !ok {
+		gologger.Warning().Msgf("access reviewer does not support rules reviews, using access reviews\n")
+		return
+	}
+
+	for _, ns := range namespaces {
+		rr, err := requestRulesReview(r.Context, rulesReviewer, ns)
+		if err != nil {
+			gologger.Warning().Msgf("could not review rules of namespace [%s], using SelfSubjectAccessReview. error: %s\n", ns, err)
+			continue
+		}
+
+		r.rulesReviews[ns] = rr
+		if _, ok := r.rulesReviews[""]; !ok {
+			r.rulesReviews[""] = rr
+		}
+	}
+}
+
+// requestRulesReview lists the rules the current authentication has in a namespace
+func requestRulesReview(ctx context.Context, rulesReviewer RulesReviewer, ns string) (*rulesReview, error) {
+	status, err := rulesReviewer.ReviewRules(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	rr := &rulesReview{
+		namespace: ns,
+		status:    *status,
+	}
+
+	if rr.status.Incomplete {
+		gologger.Warning().Msgf(
+			"rules review for namespace [%s] is incomplete, unsettled verbs will be tested with SelfSubjectAccessReview. error: %s\n",
+			ns,
+			rr.status.EvaluationError,
+		)
+	}
+
+	return rr, nil
+}
+
+// settle tries to decide if a verb is allowed in a resource only using the rules of the review
+//
+// When the rules are not enough to take a decision, settled is false and the verb must be
+// tested with a SelfSubjectAccessReview. This happens when:
+//   - the review is incomplete and no rule allows the verb
+//   - the only matching rules are restricted to resource names
+//   - the resource is cluster-wide, as the rules of a namespace may come from a RoleBinding,
+//     which does not grant access to cluster-wide resources
+func (rr *rulesReview) settle(verb string, resource *Resource) (review *v1.SelfSubjectAccessReview, settled bool) {
+	if resource.IsNonResource() {
+		return rr.settleNonResource(verb, resource)
+	}
+
+	matched := false
+	matchedByName := false
+
+	for _, rule := range rr.status.ResourceRules {
+		if !ruleValueMatches(rule.Verbs, verb) ||
+			!ruleValueMatches(rule.APIGroups, resource.GroupName) ||
+			!ruleResourceMatches(rule.Resources, resource) {
+			continue
+		}
+
+		if len(rule.ResourceNames) > 0 {
+			matchedByName = true
+			continue
+		}
+
+		matched = true
+		break
+	}
+
+	switch {
+	case matched && !resource.Namespaced:
+		return nil, false
+	case matched:
+		return rr.review(verb, resource, true), true
+	case rr.status.Incomplete || matchedByName:
+		return nil, false
+	default:
+		return rr.review(verb, resource, false), true
+	}
+}
+
+// review creates an access review equivalent to the decision taken from the rules
+func (rr *rulesReview) review(verb string, resource *Resource, allowed bool) *v1.SelfSubjectAccessReview {
+	review := &v1.SelfSubjectAccessReview{
+		Status: v1.SubjectAccessReviewStatus{
+			Allowed: allowed,
+		},
+	}
+
+	if resource.IsNonResource() {
+		review.Spec.NonResourceAttributes = &v1.NonResourceAttributes{
+			Path: resource.NonResourceURL,
+			Verb: verb,
+		}
+	} else {
+		review.Spec.ResourceAttributes = &v1.ResourceAttributes{
+			Verb:        verb,
+			Resource:    resource.Name,
+			Group:       resource.GroupName,
+			Subresource: resource.SubResource,
+		}
+
+		if resource.Namespaced {
+			review.Spec.ResourceAttributes.Namespace = rr.namespace
+		}
+	}
+
+	if allowed {
+		review.Status.Reason = rulesReviewReason
+	}
+
+	return review
+}
+
+// ruleValueMatches checks if a rule list contains the value or the wildcard
+func ruleValueMatches(ruleValues []string, value string) bool {
+	for _, ruleValue := range ruleValues {
+		if ruleValue == "*" || ruleValue == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ruleResourceMatches checks if a rule list of resources contains the resource
+//
+// It follows the same semantics as the RBAC authorizer: `*` matches every resource and
+// sub-resource, `pods/exec` matches exactly and `*/scale` matches the sub-resource of any resource
+func ruleResourceMatches(ruleResources []string, resource *Resource) bool {
+	combined := resource.Name
+	if resource.SubResource != "" {
+		combined += "/" + resource.SubResource
+	}
+
+	for _, ruleResource := range ruleResources {
+		if ruleResource == "*" || ruleResource == combined {
+			return true
+		}
+
+		if resource.SubResource != "" && ruleResource == "*/"+resource.SubResource {
+			return true
+		}
+	}
+
+	return false
+}
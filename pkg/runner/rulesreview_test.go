@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/authorization/v1"
+)
+
+func TestRulesReviewSettle(t *testing.T) {
+	pods := &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true}
+	podsExec := &Resource{GroupVersion: "v1", Name: "pods", SubResource: "exec", Namespaced: true}
+	deploymentsScale := &Resource{GroupName: "apps", GroupVersion: "v1", Name: "deployments", SubResource: "scale", Namespaced: true}
+	nodes := &Resource{GroupVersion: "v1", Name: "nodes"}
+
+	rr := &rulesReview{
+		namespace: "default",
+		status: v1.SubjectRulesReviewStatus{
+			ResourceRules: []v1.ResourceRule{
+				{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+				{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*/scale"}},
+				{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}, ResourceNames: []string{"debug"}},
+				{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		verb            string
+		resource        *Resource
+		expectedSettled bool
+		expectedAllowed bool
+	}{
+		{"exact match", "get", pods, true, true},
+		{"verb not in rule", "delete", pods, true, false},
+		{"subresource not covered by resource rule", "get", podsExec, true, false},
+		{"wildcard subresource", "patch", deploymentsScale, true, true},
+		{"restricted to resource names", "create", podsExec, false, false},
+		{"cluster-wide resource", "get", nodes, false, false},
+		{"cluster-wide resource denied", "delete", nodes, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review, settled := rr.settle(tt.verb, tt.resource)
+			if settled != tt.expectedSettled {
+				t.Fatalf("expected settled = %v, got %v", tt.expectedSettled, settled)
+			}
+
+			if settled && review.Status.Allowed != tt.expectedAllowed {
+				t.Fatalf("expected allowed = %v, got %v", tt.expectedAllowed, review.Status.Allowed)
+			}
+		})
+	}
+}
+
+func TestIncompleteRulesReviewSettle(t *testing.T) {
+	rr := &rulesReview{
+		namespace: "default",
+		status: v1.SubjectRulesReviewStatus{
+			ResourceRules: []v1.ResourceRule{
+				{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			},
+			Incomplete: true,
+		},
+	}
+	pods := &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true}
+
+	if _, settled := rr.settle("get", pods); !settled {
+		t.Fatal("expected allowed verb to be settled in an incomplete review")
+	}
+
+	if _, settled := rr.settle("delete", pods); settled {
+		t.Fatal("expected denied verb to be unsettled in an incomplete review")
+	}
+}
+
+func TestRulesReviewSettleNonResource(t *testing.T) {
+	rr := &rulesReview{
+		namespace: "default",
+		status: v1.SubjectRulesReviewStatus{
+			NonResourceRules: []v1.NonResourceRule{
+				{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz", "/debug/*"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		verb            string
+		url             string
+		expectedAllowed bool
+	}{
+		{"get", "/healthz", true},
+		{"get", "/debug/pprof", true},
+		{"post", "/healthz", false},
+		{"get", "/metrics", false},
+	}
+
+	for _, tt := range tests {
+		review, settled := rr.settle(tt.verb, &Resource{NonResourceURL: tt.url})
+		if !settled {
+			t.Fatalf("expected [%s] -> [%s] to be settled", tt.verb, tt.url)
+		}
+
+		if review.Status.Allowed != tt.expectedAllowed {
+			t.Fatalf("expected [%s] -> [%s] allowed = %v", tt.verb, tt.url, tt.expectedAllowed)
+		}
+	}
+}
@@ -1,262 +1,376 @@
 package runner
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 
 	myK8s "github.com/ing-bank/kal/pkg/kubernetes"
-	"github.com/ing-bank/kal/pkg/types"
 	"github.com/projectdiscovery/gologger"
 	"golang.org/x/sync/semaphore"
 	v1 "k8s.io/api/authorization/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // Exec will execute the procedure to list all permissions from a given configuration
 //
-// return : resourcePermissions map[resourceName+version+subresource][]ApiVerb
-func (r *Runner) Exec() (resourcePermissions map[string][]string) {
-	resourcePermissions = make(map[string][]string, 0)
-
-	gologger.Info().Msgf("running from namespace = %s\n", r.Namespace)
-
-	apiGroupsList := make([]string, 0)
-
-	groupList, _, _, err := r.KubernetesClient.GroupsAndMaybeResources()
-	if err != nil {
-		gologger.Error().Msgf("could not list api resources")
-		return
+// It returns the allowed verbs of every resource, sub-resource and non-resource URL, or an error
+// when the resources could not be listed. Exec can be called again once it returned, until Close
+func (r *Runner) Exec() (*PermissionSet, error) {
+	if err := r.Context.Err(); err != nil {
+		return nil, fmt.Errorf("runner closed: %w", err)
 	}
 
-	for _, group := range groupList.Groups {
-		for _, version := range group.Versions {
-			apiGroupsList = append(apiGroupsList, version.GroupVersion)
-		}
+	// without a client, New leaves the reviewer and the discoverer to the options
+	if r.reviewer == nil {
+		return nil, errors.New("no access reviewer, use a kubernetes client or WithAccessReviewer")
+	}
+	if r.discoverer == nil {
+		return nil, errors.New("no discoverer, use a kubernetes client or WithDiscoverer")
 	}
 
-	resources := make([]*Resource, 0)
-	for _, group := range apiGroupsList {
-		groupResources, err := r.KubernetesClient.DiscoveryClient.ServerResourcesForGroupVersion(group)
+	permissions := NewPermissionSet()
 
-		if err != nil {
-			gologger.Error().Msgf("could not get resources from group. error: %s\n", err)
-			continue
-		}
+	// the token is verified before it is used by the analysis, listing the namespaces included
+	var verification *myK8s.TokenVerification
+	if r.VerifyToken {
+		verification = r.verifyToken()
+	}
 
-		for _, resource := range groupResources.APIResources {
-			y := strings.Split(groupResources.GroupVersion, "/")
+	namespaces, allNamespaces := r.resolveNamespaces()
+	if len(namespaces) == 0 {
+		return nil, errors.New("no namespaces to analyze")
+	}
 
-			groupName := y[0]
-			var groupVersion string
-			if len(y) > 1 {
-				groupVersion = y[1]
-			}
+	r.target = &Target{
+		Label:         r.Label,
+		ServerURL:     r.ServerURL,
+		Context:       r.KubeContext,
+		Cluster:       r.KubeCluster,
+		Identity:      r.resolveIdentity(),
+		Namespaces:    namespaces,
+		AllNamespaces: allNamespaces,
+		AllResults:    r.ShowAll,
+
+		TokenVerification: verification,
+	}
 
-			resourceName := resource.Name
-			subResource := ""
-			if strings.Contains(resourceName, "/") {
-				// example: helmchartrepositories/status -> status is the sub-resource of helmchartrepositories
+	gologger.Info().Msgf("running from namespaces = %s\n", strings.Join(namespaces, ","))
+	if r.Impersonation.UserName != "" {
+		gologger.Info().Msgf("impersonating %s\n", identityFromImpersonation(r.Impersonation))
+	}
 
-				x := strings.Split(resourceName, "/")
-				resourceName = x[0]
-				subResource = x[1]
-			}
+	if r.target.Identity != nil {
+		gologger.Info().Msgf("authenticated as %s (from %s)\n", r.target.Identity, r.target.Identity.Source)
+	} else {
+		gologger.Warning().Msg("could not find the identity of the authentication")
+	}
 
-			resourceItem := &Resource{
-				GroupName:    groupName,
-				GroupVersion: groupVersion,
-				Name:         resourceName,
-				Namespaced:   resource.Namespaced,
-				SubResource:  subResource,
-			}
-			if groupName == "v1" {
-				resourceItem.GroupVersion = groupName
-				resourceItem.GroupName = ""
-			}
+	resources, err := r.discoverer.Resources(r.Context)
+	if err != nil {
+		return nil, fmt.Errorf("could not list api resources: %w", err)
+	}
 
-			resources = append(
-				resources,
-				resourceItem,
-			)
+	resources = append(resources, virtualResources(resources)...)
 
-		}
+	if !r.SkipNonResource {
+		resources = append(resources, r.nonResources()...)
 	}
 
-	// output processor start
-
-	r.outputWg.Add(1)
-	go func(output chan *Result, rp *map[string][]string) {
-		defer r.outputWg.Done()
+	// the counters and the output of the analysis are reset by every execution
+	r.reviewMutex.Lock()
+	r.reviewRequests, r.reviewFailures, r.reviewErr = 0, 0, nil
+	r.reviewMutex.Unlock()
+	r.rulesReviews = nil
 
-		for outputResult := range output {
-			if len(outputResult.AllowedVerbs) > 0 {
-				if _, ok := (*rp)[outputResult.Resource.Name]; !ok {
-					(*rp)[outputResult.Resource.Name] = make([]string, 0)
-				}
+	// output processor start
 
-				resultKey := outputResult.Resource.Name
+	output := make(chan *Result)
+	var outputWg sync.WaitGroup
 
-				if outputResult.Resource.GroupVersion != "" {
-					resultKey += "/" + outputResult.Resource.GroupVersion
-				}
+	outputWg.Add(1)
+	go func(output chan *Result, ps *PermissionSet) {
+		defer outputWg.Done()
 
-				if outputResult.Resource.SubResource != "" {
-					resultKey += "/" + outputResult.Resource.SubResource
-				}
+		for outputResult := range output {
+			ps.Add(outputResult)
 
-				(*rp)[resultKey] = append((*rp)[outputResult.Resource.Name], outputResult.AllowedVerbs...)
+			if !r.ShowAll && len(outputResult.AllowedVerbs) == 0 {
+				continue
 			}
 
-			if !r.ShowAll && len(outputResult.AllowedVerbs) == 0 {
+			if r.sink == nil {
 				continue
 			}
 
-			if len(outputResult.str) > 0 {
-				gologger.Silent().Msgf("%s\n", outputResult.str)
+			if err := r.sink.Write(outputResult); err != nil {
+				gologger.Error().Msgf("could not write result [%s]. error: %s\n", outputResult.Resource.String(), err)
 			}
 		}
-	}(r.outputChan, &resourcePermissions)
+	}(output, permissions)
+
+	if r.RulesReview {
+		r.requestRulesReviews(namespaces)
+	}
+
+	r.requestSem = nil
+	if r.MaxRequests > 0 {
+		r.requestSem = semaphore.NewWeighted(int64(r.MaxRequests))
+	}
+
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
 
 	var analysisWg sync.WaitGroup
-	sem := semaphore.NewWeighted(1)
-	semCtx := context.TODO()
+	sem := semaphore.NewWeighted(int64(concurrency))
 
-	gologger.Info().Msgf("found %d resources and sub-resources\n", len(resources))
+	gologger.Info().Msgf("found %d resources, sub-resources and non-resource urls\n", len(resources))
+dispatch:
 	for _, resource := range resources {
-		_ = sem.Acquire(semCtx, 1)
-		analysisWg.Add(1)
-		go func() {
-			defer sem.Release(1)
-			defer analysisWg.Done()
-			r.outputChan <- r.analysis(resource)
-		}()
+		// cluster-wide resources and non-resource urls are analyzed only once
+		resourceNamespaces := []string{""}
+		if resource.Namespaced {
+			resourceNamespaces = namespaces
+		}
+
+		for _, ns := range resourceNamespaces {
+			// no analysis is started once the runner is closed
+			if err := sem.Acquire(r.Context, 1); err != nil {
+				break dispatch
+			}
+			analysisWg.Add(1)
+			go func() {
+				defer sem.Release(1)
+				defer analysisWg.Done()
+				output <- r.analysis(resource, ns)
+			}()
+		}
 	}
 
 	analysisWg.Wait()
 
-	close(r.outputChan)
-	r.outputWg.Wait()
-	return
+	close(output)
+	outputWg.Wait()
+
+	if err := r.Context.Err(); err != nil {
+		return nil, fmt.Errorf("analysis interrupted: %w", err)
+	}
+
+	// an authentication rejected by the api fails every request, like an expired token
+	if r.reviewRequests > 0 && r.reviewFailures == r.reviewRequests {
+		return nil, fmt.Errorf("every access review request failed: %w", r.reviewErr)
+	}
+
+	return permissions, nil
 }
 
-func (r *Runner) analysis(resource *Resource) (result *Result) {
+func (r *Runner) analysis(resource *Resource, ns string) (result *Result) {
 	result = &Result{
+		Target:                         r.target,
 		Resource:                       resource,
-		Namespace:                      r.Namespace,
+		Namespace:                      ns,
 		SelfSubjectAccessReviewResults: make([]*v1.SelfSubjectAccessReview, 0),
 		AllowedVerbs:                   make([]string, 0),
+		DeniedVerbs:                    make([]string, 0),
 	}
 
-	var verbWg sync.WaitGroup
-	var verbChanWg sync.WaitGroup
-	verbChan := make(chan string)
-	accessReviewChan := make(chan *v1.SelfSubjectAccessReview)
-
-	verbChanWg.Add(1)
-	go func() {
-		for allowedVerb := range verbChan {
-			result.AllowedVerbs = append(result.AllowedVerbs, allowedVerb)
-			result.SelfSubjectAccessReviewResults = append(result.SelfSubjectAccessReviewResults, <-accessReviewChan)
-		}
-		verbChanWg.Done()
-	}()
+	verbs := r.verbsFor(resource)
+	reviews := make([]*v1.SelfSubjectAccessReview, len(verbs))
 
-	for _, verb := range myK8s.ApiVerbs {
-		gologger.Debug().Msgf("testing resource [%s] -> VERB[%s] NS[%s]\n", resource.String(), verb, r.Namespace)
-
-		ns := r.Namespace
-		if !resource.Namespaced {
-			ns = ""
-		}
+	var verbWg sync.WaitGroup
+	for i, verb := range verbs {
+		gologger.Debug().Msgf("testing resource [%s] -> VERB[%s] NS[%s]\n", resource.String(), verb, ns)
 
 		verbWg.Add(1)
 		go func(vb, nspace string, resource *Resource) {
 			defer verbWg.Done()
 
-			verbAccessReview := r.requestAccessReview(vb, nspace, resource)
-			if verbAccessReview.Status.Allowed {
-				verbChan <- verb
-				accessReviewChan <- verbAccessReview
+			verbAccessReview, settled := r.settleFromRules(vb, nspace, resource)
+			if !settled {
+				verbAccessReview = r.requestAccessReview(vb, nspace, resource)
 			}
-		}(verb, ns, resource)
 
+			// every goroutine writes in its own index, keeping the order of the verbs
+			reviews[i] = verbAccessReview
+		}(verb, ns, resource)
 	}
 	verbWg.Wait()
-	close(verbChan)
-	close(accessReviewChan)
-	verbChanWg.Wait()
 
-	builder := &strings.Builder{}
+	for i, review := range reviews {
+		result.SelfSubjectAccessReviewResults = append(result.SelfSubjectAccessReviewResults, review)
 
-	builder.WriteString(resource.String())
+		if review.Status.Allowed {
+			result.AllowedVerbs = append(result.AllowedVerbs, verbs[i])
+		} else {
+			result.DeniedVerbs = append(result.DeniedVerbs, verbs[i])
+		}
+	}
 
-	if len(result.AllowedVerbs) > 0 {
-		builder.WriteString(" [")
-		builder.WriteString(types.AU.Green(strings.Join(result.AllowedVerbs, ",")).String())
-		builder.WriteRune(']')
-	} else {
-		builder.WriteString(" [")
-		builder.WriteString(types.AU.Red("NO_ALLOWED_VERBS").String())
-		builder.WriteRune(']')
+	return result
+}
+
+// settleFromRules decides if a verb is allowed using the rules review of the namespace
+//
+// It returns settled = false when the rules review mode is disabled or the rules are not enough to decide
+func (r *Runner) settleFromRules(verb, ns string, resource *Resource) (*v1.SelfSubjectAccessReview, bool) {
+	rr, ok := r.rulesReviews[ns]
+	if !ok {
+		return nil, false
 	}
 
-	ns := ""
-	if result.Resource.Namespaced {
-		ns = result.Namespace
-	} else {
-		ns = "CLUSTER_WIDE"
+	review, settled := rr.settle(verb, resource)
+	if !settled {
+		gologger.Debug().Msgf("rules review could not settle [%s] -> VERB[%s]\n", resource.String(), verb)
+	}
+
+	return review, settled
+}
+
+// verbsFor returns the API verbs to be tested in a resource
+//
+// Unless AllVerbs is set, only the verbs supported by the resource and the special verbs
+// meaningful to it are tested. When discovery does not report the verbs, all verbs are tested.
+// The virtual resources, like users and signers, are always tested with their special verbs only
+func (r *Runner) verbsFor(resource *Resource) []string {
+	if resource.IsNonResource() {
+		return myK8s.NonResourceVerbs
 	}
-	builder.WriteString(" [")
-	builder.WriteString(types.AU.Blue(ns).String())
-	builder.WriteRune(']')
 
-	if r.ShowReason {
-		builder.WriteString(" [")
-		reasons := make([]string, 0)
-		for _, review := range result.SelfSubjectAccessReviewResults {
-			reasons = append(reasons, review.Status.Reason)
+	groupResource := schema.GroupResource{Group: resource.GroupName, Resource: resource.Name}
+
+	// the virtual resources only have a meaning for their special verbs, even with all the verbs
+	if r.AllVerbs {
+		if resource.SubResource == "" && isVirtualResource(groupResource) {
+			return myK8s.SpecialVerbs[groupResource]
 		}
-		builder.WriteString(types.AU.Magenta(strings.Join(reasons, ";")).String())
-		builder.WriteRune(']')
+		return myK8s.ApiVerbs
 	}
 
-	result.str = builder.String()
+	supported := make(map[string]struct{}, len(resource.Verbs))
+	for _, verb := range resource.Verbs {
+		supported[verb] = struct{}{}
+	}
 
-	return result
+	if resource.SubResource == "" {
+		for _, verb := range myK8s.SpecialVerbs[groupResource] {
+			supported[verb] = struct{}{}
+		}
+	}
+
+	if len(supported) == 0 {
+		return myK8s.ApiVerbs
+	}
+
+	verbs := make([]string, 0, len(supported))
+	for _, verb := range myK8s.ApiVerbs {
+		if _, ok := supported[verb]; ok {
+			verbs = append(verbs, verb)
+		}
+	}
+
+	return verbs
 }
 
-func (r *Runner) requestAccessReview(verb, ns string, resource *Resource) *v1.SelfSubjectAccessReview {
-	sar := &v1.SelfSubjectAccessReview{
-		Spec: v1.SelfSubjectAccessReviewSpec{
-			ResourceAttributes: &v1.ResourceAttributes{
-				Verb:        verb,
-				Resource:    resource.Name,
-				Group:       resource.GroupName,
-				Subresource: resource.SubResource,
-				Namespace:   ns,
-				Name:        resource.Name,
-			},
-		},
+// isVirtualResource checks if a resource is one of the virtual resources
+func isVirtualResource(groupResource schema.GroupResource) bool {
+	for _, gvr := range myK8s.VirtualResources {
+		if gvr.GroupResource() == groupResource {
+			return true
+		}
 	}
 
-	if resource.Namespaced {
-		sar.Spec.ResourceAttributes.Namespace = ns
+	return false
+}
+
+// virtualResources returns the virtual resources that were not found by the discovery
+func virtualResources(discovered []*Resource) []*Resource {
+	found := make(map[schema.GroupResource]struct{}, len(discovered))
+	for _, resource := range discovered {
+		if resource.SubResource == "" {
+			found[schema.GroupResource{Group: resource.GroupName, Resource: resource.Name}] = struct{}{}
+		}
 	}
 
-	accessReviewResponse, err := r.KubernetesClient.
-		AuthorizationV1().
-		SelfSubjectAccessReviews().
-		Create(
-			r.Context, // All the requests use the same context for rate limit control
-			sar,
-			metav1.CreateOptions{},
-		)
+	resources := make([]*Resource, 0, len(myK8s.VirtualResources))
+	for _, gvr := range myK8s.VirtualResources {
+		if _, ok := found[gvr.GroupResource()]; ok {
+			continue
+		}
+
+		resources = append(resources, &Resource{
+			GroupName:    gvr.Group,
+			GroupVersion: gvr.Version,
+			Name:         gvr.Resource,
+			Verbs:        myK8s.SpecialVerbs[gvr.GroupResource()],
+		})
+	}
+
+	return resources
+}
+
+func (r *Runner) requestAccessReview(verb, ns string, resource *Resource) *v1.SelfSubjectAccessReview {
+	spec := v1.SelfSubjectAccessReviewSpec{}
 
+	if resource.IsNonResource() {
+		spec.NonResourceAttributes = &v1.NonResourceAttributes{
+			Path: resource.NonResourceURL,
+			Verb: verb,
+		}
+	} else {
+		spec.ResourceAttributes = &v1.ResourceAttributes{
+			Verb:        verb,
+			Resource:    resource.Name,
+			Group:       resource.GroupName,
+			Subresource: resource.SubResource,
+			Name:        resource.Name,
+		}
+
+		if resource.Namespaced {
+			spec.ResourceAttributes.Namespace = ns
+		}
+	}
+
+	// limits the number of in-flight access review requests, until the runner is closed
+	if r.requestSem != nil {
+		if err := r.requestSem.Acquire(r.Context, 1); err != nil {
+			return failedAccessReview(spec, err)
+		}
+		defer r.requestSem.Release(1)
+	}
+
+	// All the requests use the same context for rate limit control
+	accessReviewResponse, err := r.reviewer.ReviewAccess(r.Context, spec)
+	r.countAccessReview(err)
 	if err != nil {
-		gologger.Error().Msgf("could not analyze resource [%s] -> [%s] (%s)\n", verb, resource.Name, ns)
-		return accessReviewResponse
+		gologger.Error().Msgf("could not analyze resource [%s] -> [%s] (%s)\n", verb, resource.String(), ns)
+		return failedAccessReview(spec, err)
 	}
 
 	return accessReviewResponse
 }
+
+// countAccessReview counts an access review request and its error
+func (r *Runner) countAccessReview(err error) {
+	r.reviewMutex.Lock()
+	defer r.reviewMutex.Unlock()
+
+	r.reviewRequests++
+	if err != nil {
+		r.reviewFailures++
+		r.reviewErr = err
+	}
+}
+
+// failedAccessReview returns a not allowed access review holding the error of the request
+func failedAccessReview(spec v1.SelfSubjectAccessReviewSpec, err error) *v1.SelfSubjectAccessReview {
+	return &v1.SelfSubjectAccessReview{
+		Spec: spec,
+		Status: v1.SubjectAccessReviewStatus{
+			Allowed:         false,
+			EvaluationError: err.Error(),
+		},
+	}
+}
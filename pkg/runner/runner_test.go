@@ -0,0 +1,424 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	myK8s "github.com/ing-bank/kal/pkg/kubernetes"
+	v1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeReviewer allows the verbs of a static list of resources
+type fakeReviewer struct {
+	allowed map[string][]string
+}
+
+func (fr *fakeReviewer) ReviewAccess(_ context.Context, spec v1.SelfSubjectAccessReviewSpec) (*v1.SelfSubjectAccessReview, error) {
+	review := &v1.SelfSubjectAccessReview{Spec: spec}
+
+	key, verb := "", ""
+	if spec.ResourceAttributes != nil {
+		key = spec.ResourceAttributes.Namespace + "/" + spec.ResourceAttributes.Resource
+		verb = spec.ResourceAttributes.Verb
+	} else {
+		key = spec.NonResourceAttributes.Path
+		verb = spec.NonResourceAttributes.Verb
+	}
+
+	for _, allowedVerb := range fr.allowed[key] {
+		if allowedVerb == verb {
+			review.Status.Allowed = true
+		}
+	}
+
+	return review, nil
+}
+
+// peakReviewer records the peak of in-flight access reviews, and calls onReview before every review
+type peakReviewer struct {
+	mutex    sync.Mutex
+	inFlight int
+	peak     int
+	reviews  int
+	onReview func(reviews int)
+}
+
+func (pr *peakReviewer) ReviewAccess(ctx context.Context, spec v1.SelfSubjectAccessReviewSpec) (*v1.SelfSubjectAccessReview, error) {
+	pr.mutex.Lock()
+	pr.inFlight++
+	pr.reviews++
+	pr.peak = max(pr.peak, pr.inFlight)
+	if pr.onReview != nil {
+		pr.onReview(pr.reviews)
+	}
+	pr.mutex.Unlock()
+
+	time.Sleep(2 * time.Millisecond)
+
+	pr.mutex.Lock()
+	pr.inFlight--
+	pr.mutex.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &v1.SelfSubjectAccessReview{Spec: spec}, nil
+}
+
+type fakeDiscoverer struct {
+	resources []*Resource
+}
+
+func (fd *fakeDiscoverer) Resources(_ context.Context) ([]*Resource, error) {
+	return fd.resources, nil
+}
+
+func (fd *fakeDiscoverer) NonResourceURLs(_ context.Context) ([]string, error) {
+	return []string{"/healthz"}, nil
+}
+
+func TestRunnerExec(t *testing.T) {
+	reviewer := &fakeReviewer{
+		allowed: map[string][]string{
+			"a/pods":   {"get", "list"},
+			"b/pods":   {"delete"},
+			"/nodes":   {"list"},
+			"/healthz": {"get"},
+		},
+	}
+	discoverer := &fakeDiscoverer{
+		resources: []*Resource{
+			{GroupVersion: "v1", Name: "pods", Namespaced: true, Verbs: []string{"get", "list", "delete"}},
+			{GroupVersion: "v1", Name: "nodes", Verbs: []string{"get", "list"}},
+		},
+	}
+
+	var mutex sync.Mutex
+	results := make([]*Result, 0)
+
+	r := New(
+		nil,
+		WithAccessReviewer(reviewer),
+		WithDiscoverer(discoverer),
+		WithResultCallback(func(result *Result) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			results = append(results, result)
+		}),
+	)
+	r.Namespaces = []string{"a", "b"}
+	r.SkipNonResource = false
+
+	permissions, err := r.Exec()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	pods := schema.GroupVersionResource{Resource: "pods"}
+	nodes := schema.GroupVersionResource{Resource: "nodes"}
+
+	if !permissions.Can("list", pods, "a") || permissions.Can("delete", pods, "a") {
+		t.Fatal("unexpected pods permissions in namespace a")
+	}
+
+	if !permissions.Can("delete", pods, "b") || permissions.Can("get", pods, "b") {
+		t.Fatal("unexpected pods permissions in namespace b")
+	}
+
+	if !permissions.Can("list", nodes, "") {
+		t.Fatal("expected cluster-wide list nodes permission")
+	}
+
+	if !permissions.CanAccessURL("get", "/healthz") {
+		t.Fatal("expected get /healthz permission")
+	}
+
+	// pods (a), pods (b), nodes and /healthz have allowed verbs
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results in the sink, got %d", len(results))
+	}
+}
+
+func TestRunnerExecTwiceAndClose(t *testing.T) {
+	reviewer := &fakeReviewer{allowed: map[string][]string{"default/pods": {"get"}}}
+	discoverer := &fakeDiscoverer{
+		resources: []*Resource{{GroupVersion: "v1", Name: "pods", Namespaced: true, Verbs: []string{"get", "list"}}},
+	}
+
+	var mutex sync.Mutex
+	results := 0
+
+	r := New(
+		nil,
+		WithAccessReviewer(reviewer),
+		WithDiscoverer(discoverer),
+		WithResultCallback(func(*Result) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			results++
+		}),
+	)
+	r.Namespaces = []string{"default"}
+
+	pods := schema.GroupVersionResource{Resource: "pods"}
+	for i := 0; i < 2; i++ {
+		permissions, err := r.Exec()
+		if err != nil {
+			t.Fatalf("unexpected error in execution %d: %s", i, err)
+		}
+
+		if !permissions.Can("get", pods, "default") {
+			t.Fatalf("expected get pods permission in execution %d", i)
+		}
+	}
+
+	if results != 2 {
+		t.Errorf("expected a result per execution, got %d", results)
+	}
+
+	r.Close()
+	r.Close()
+
+	if _, err := r.Exec(); err == nil {
+		t.Error("expected an error after Close")
+	}
+}
+
+func TestRunnerExecWithoutClient(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"no options", nil},
+		{"no discoverer", []Option{WithAccessReviewer(&fakeReviewer{})}},
+		{"no access reviewer", []Option{WithDiscoverer(&fakeDiscoverer{})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(nil, tt.opts...)
+			r.Namespaces = []string{"default"}
+
+			if _, err := r.Exec(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestResolveIdentityFromToken(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "system:serviceaccount:monitoring:prometheus",
+		"kubernetes.io": map[string]any{
+			"namespace":      "monitoring",
+			"serviceaccount": map[string]any{"name": "prometheus", "uid": "1234"},
+		},
+	}).SignedString([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fake reviewer does not implement IdentityReviewer, like older servers
+	r := New(nil, WithAccessReviewer(&fakeReviewer{}), WithDiscoverer(&fakeDiscoverer{}))
+	r.token = token
+
+	identity := r.resolveIdentity()
+	if identity == nil {
+		t.Fatal("expected an identity from the token claims")
+	}
+
+	if identity.Username != "system:serviceaccount:monitoring:prometheus" || identity.UID != "1234" || identity.Source != IdentitySourceToken {
+		t.Errorf("unexpected identity: %s (%s)", identity, identity.Source)
+	}
+}
+
+func TestVerbsFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource *Resource
+		allVerbs bool
+		expected []string
+	}{
+		{
+			name:     "serviceaccounts",
+			resource: &Resource{GroupVersion: "v1", Name: "serviceaccounts", Namespaced: true, Verbs: []string{"get", "list", "create"}},
+			expected: []string{"create", "get", "list", "impersonate"},
+		},
+		{
+			name:     "serviceaccounts token",
+			resource: &Resource{GroupVersion: "v1", Name: "serviceaccounts", SubResource: "token", Namespaced: true, Verbs: []string{"create"}},
+			expected: []string{"create"},
+		},
+		{
+			name:     "clusterroles",
+			resource: &Resource{GroupName: "rbac.authorization.k8s.io", GroupVersion: "v1", Name: "clusterroles", Verbs: []string{"get", "list", "update"}},
+			expected: []string{"get", "list", "update", "bind", "escalate"},
+		},
+		{
+			name:     "signers",
+			resource: &Resource{GroupName: "certificates.k8s.io", GroupVersion: "v1", Name: "signers"},
+			expected: []string{"approve"},
+		},
+		{
+			name:     "no verbs discovered",
+			resource: &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true},
+			expected: myK8s.ApiVerbs,
+		},
+		{
+			name:     "all verbs",
+			resource: &Resource{GroupVersion: "v1", Name: "pods", Namespaced: true, Verbs: []string{"get"}},
+			allVerbs: true,
+			expected: myK8s.ApiVerbs,
+		},
+		{
+			name:     "signers with all verbs",
+			resource: &Resource{GroupName: "certificates.k8s.io", GroupVersion: "v1", Name: "signers", Verbs: []string{"approve"}},
+			allVerbs: true,
+			expected: []string{"approve"},
+		},
+		{
+			name:     "users with all verbs",
+			resource: &Resource{GroupVersion: "v1", Name: "users", Verbs: []string{"impersonate"}},
+			allVerbs: true,
+			expected: []string{"impersonate"},
+		},
+		{
+			name:     "clusterroles with all verbs",
+			resource: &Resource{GroupName: "rbac.authorization.k8s.io", GroupVersion: "v1", Name: "clusterroles", Verbs: []string{"get"}},
+			allVerbs: true,
+			expected: myK8s.ApiVerbs,
+		},
+		{
+			name:     "non-resource url",
+			resource: &Resource{NonResourceURL: "/healthz"},
+			expected: myK8s.NonResourceVerbs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(nil)
+			r.AllVerbs = tt.allVerbs
+
+			if verbs := r.verbsFor(tt.resource); !reflect.DeepEqual(verbs, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, verbs)
+			}
+		})
+	}
+}
+
+func TestVirtualResources(t *testing.T) {
+	discovered := []*Resource{
+		{GroupVersion: "v1", Name: "serviceaccounts", Namespaced: true, Verbs: []string{"get"}},
+		{GroupName: "certificates.k8s.io", GroupVersion: "v1", Name: "signers", SubResource: "status"},
+	}
+
+	verbs := make(map[string][]string)
+	for _, resource := range virtualResources(discovered) {
+		if resource.Namespaced {
+			t.Errorf("expected the virtual resource %s to be cluster-wide", resource.Name)
+		}
+		verbs[schema.GroupResource{Group: resource.GroupName, Resource: resource.Name}.String()] = resource.Verbs
+	}
+
+	expected := map[string][]string{
+		"users":                       {"impersonate"},
+		"groups":                      {"impersonate"},
+		"uids.authentication.k8s.io":  {"impersonate"},
+		"signers.certificates.k8s.io": {"approve"},
+	}
+	if !reflect.DeepEqual(verbs, expected) {
+		t.Errorf("expected %v, got %v", expected, verbs)
+	}
+
+	// a discovered resource is not added again
+	discovered = append(discovered, &Resource{GroupName: "certificates.k8s.io", GroupVersion: "v1", Name: "signers", Verbs: []string{"approve"}})
+	for _, resource := range virtualResources(discovered) {
+		if resource.Name == "signers" {
+			t.Error("expected the discovered signers not to be a virtual resource")
+		}
+	}
+}
+
+func TestRunnerExecLimits(t *testing.T) {
+	// single verb resources, every analysis sends one access review
+	resources := make([]*Resource, 0, 20)
+	for i := 0; i < 20; i++ {
+		resources = append(resources, &Resource{GroupVersion: "v1", Name: fmt.Sprintf("resource%d", i), Verbs: []string{"get"}})
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		maxRequests int
+		peak        int
+	}{
+		{"sequential", 1, 0, 1},
+		{"concurrency", 4, 0, 4},
+		{"max requests", 8, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviewer := &peakReviewer{}
+			r := New(nil, WithAccessReviewer(reviewer), WithDiscoverer(&fakeDiscoverer{resources: resources}))
+			r.Namespaces = []string{"default"}
+			r.SkipNonResource = true
+			r.Concurrency = tt.concurrency
+			r.MaxRequests = tt.maxRequests
+
+			if _, err := r.Exec(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			// the virtual resources are reviewed with their special verb
+			if expected := len(resources) + len(myK8s.VirtualResources); reviewer.reviews != expected {
+				t.Errorf("expected %d access reviews, got %d", expected, reviewer.reviews)
+			}
+
+			if reviewer.peak > tt.peak {
+				t.Errorf("expected at most %d in-flight access reviews, got %d", tt.peak, reviewer.peak)
+			}
+		})
+	}
+}
+
+func TestRunnerExecCancelled(t *testing.T) {
+	resources := make([]*Resource, 0, 20)
+	for i := 0; i < 20; i++ {
+		resources = append(resources, &Resource{GroupVersion: "v1", Name: fmt.Sprintf("resource%d", i), Verbs: []string{"get", "list"}})
+	}
+
+	for _, maxRequests := range []int{0, 1} {
+		t.Run(fmt.Sprintf("max requests %d", maxRequests), func(t *testing.T) {
+			reviewer := &peakReviewer{}
+			r := New(nil, WithAccessReviewer(reviewer), WithDiscoverer(&fakeDiscoverer{resources: resources}))
+			r.Namespaces = []string{"default"}
+			r.SkipNonResource = true
+			r.Concurrency = 2
+			r.MaxRequests = maxRequests
+
+			// the runner is closed by the first access review
+			reviewer.onReview = func(reviews int) {
+				if reviews == 1 {
+					r.Close()
+				}
+			}
+
+			if _, err := r.Exec(); err == nil {
+				t.Fatal("expected an error for the interrupted analysis")
+			}
+
+			if reviewer.reviews >= 2*len(resources)+len(myK8s.VirtualResources) {
+				t.Errorf("expected the analysis to stop, got %d access reviews", reviewer.reviews)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"io"
+
+	"github.com/ing-bank/kal/pkg/risk"
+	"github.com/ing-bank/kal/pkg/sarif"
+)
+
+// WriteSARIF writes the risk findings of every target of a report as a SARIF 2.1.0 log, describing
+// the rules of the risk engine
+//
+// The identity of a finding is the username of the target, or its name when the identity is unknown
+func WriteSARIF(writer io.Writer, report *Report, engine *risk.Engine) error {
+	log := sarif.NewLog(report.KALVersion, engine.Rules())
+
+	for _, targetReport := range report.Targets {
+		if targetReport.Target == nil {
+			continue
+		}
+
+		identity := targetReport.Name()
+		if targetReport.Identity != nil && targetReport.Identity.Username != "" {
+			identity = targetReport.Identity.Username
+		}
+
+		cluster := targetReport.Cluster
+		if cluster == "" {
+			cluster = targetReport.ServerURL
+		}
+
+		log.AddFindings(identity, cluster, targetReport.Findings)
+	}
+
+	return log.Write(writer)
+}
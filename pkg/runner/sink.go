@@ -0,0 +1,236 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ing-bank/kal/pkg/types"
+	"github.com/logrusorgru/aurora/v4"
+)
+
+// ResultSink receives the results of the analysis of a Runner
+//
+// A sink may be shared by many runners, so implementations must be safe for concurrent use
+type ResultSink interface {
+	Write(result *Result) error
+}
+
+// ResultSinkFunc is a function used as a ResultSink
+type ResultSinkFunc func(result *Result) error
+
+// Write calls the function with the result
+func (f ResultSinkFunc) Write(result *Result) error {
+	return f(result)
+}
+
+// MultiSink writes the results in every sink
+type MultiSink []ResultSink
+
+// Write writes the result in every sink, returning the first error
+func (ms MultiSink) Write(result *Result) error {
+	var firstErr error
+	for _, sink := range ms {
+		if err := sink.Write(result); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// TextSink writes every result as a colored line
+type TextSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	au     *aurora.Aurora
+
+	// ShowReason adds the reasons of the allowed verbs to the line
+	ShowReason bool
+	// ShowTarget prefixes the line with the name of the target of the result
+	ShowTarget bool
+}
+
+// NewTextSink creates a TextSink, a nil aurora disables colors
+func NewTextSink(writer io.Writer, au *aurora.Aurora) *TextSink {
+	if au == nil {
+		au = aurora.New(aurora.WithColors(false))
+	}
+
+	return &TextSink{writer: writer, au: au}
+}
+
+// Write writes the line of the result
+func (ts *TextSink) Write(result *Result) error {
+	line := ts.format(result)
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	_, err := io.WriteString(ts.writer, line+"\n")
+	return err
+}
+
+func (ts *TextSink) format(result *Result) string {
+	builder := &strings.Builder{}
+
+	if ts.ShowTarget && result.Target != nil {
+		builder.WriteRune('[')
+		builder.WriteString(ts.au.Cyan(result.Target.Name()).String())
+		builder.WriteString("] ")
+	}
+
+	builder.WriteString(result.Resource.String())
+
+	if len(result.AllowedVerbs) > 0 {
+		builder.WriteString(" [")
+		builder.WriteString(ts.au.Green(strings.Join(result.AllowedVerbs, ",")).String())
+		builder.WriteRune(']')
+	} else {
+		builder.WriteString(" [")
+		builder.WriteString(ts.au.Red("NO_ALLOWED_VERBS").String())
+		builder.WriteRune(']')
+	}
+
+	scope := ""
+	switch {
+	case result.Resource.IsNonResource():
+		scope = "NON_RESOURCE"
+	case result.Resource.Namespaced:
+		scope = result.Namespace
+	default:
+		scope = "CLUSTER_WIDE"
+	}
+	builder.WriteString(" [")
+	builder.WriteString(ts.au.Blue(scope).String())
+	builder.WriteRune(']')
+
+	if ts.ShowReason {
+		builder.WriteString(" [")
+		reasons := make([]string, 0)
+		for _, review := range result.SelfSubjectAccessReviewResults {
+			if review.Status.Allowed {
+				reasons = append(reasons, review.Status.Reason)
+			}
+		}
+		builder.WriteString(ts.au.Magenta(strings.Join(reasons, ";")).String())
+		builder.WriteRune(']')
+	}
+
+	return builder.String()
+}
+
+// JSONSink writes every result as a JSON document
+type JSONSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	indent bool
+}
+
+// NewJSONSink creates a JSONSink writing indented JSON documents
+func NewJSONSink(writer io.Writer) *JSONSink {
+	return &JSONSink{writer: writer, indent: true}
+}
+
+// NewJSONLinesSink creates a JSONSink writing one JSON document per line
+func NewJSONLinesSink(writer io.Writer) *JSONSink {
+	return &JSONSink{writer: writer}
+}
+
+// Write writes the JSON document of the result
+func (js *JSONSink) Write(result *Result) error {
+	var data []byte
+	var err error
+
+	if js.indent {
+		data, err = json.MarshalIndent(result, "", "  ")
+	} else {
+		data, err = json.Marshal(result)
+	}
+	if err != nil {
+		return err
+	}
+
+	js.mutex.Lock()
+	defer js.mutex.Unlock()
+
+	_, err = js.writer.Write(append(data, '\n'))
+	return err
+}
+
+// ReportSink collects the results of one or more runners in a Report
+type ReportSink struct {
+	mutex   sync.Mutex
+	report  *Report
+	targets map[*Target]*TargetReport
+}
+
+// NewReportSink creates an empty ReportSink
+func NewReportSink() *ReportSink {
+	return &ReportSink{
+		report: &Report{
+			KALVersion: types.Version(),
+			Timestamp:  time.Now(),
+			Targets:    make([]*TargetReport, 0),
+		},
+		targets: make(map[*Target]*TargetReport),
+	}
+}
+
+// Write stores the result in the report of its target
+func (rs *ReportSink) Write(result *Result) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	targetReport := rs.targetReport(result.Target)
+	targetReport.Results = append(targetReport.Results, result)
+
+	return nil
+}
+
+// AddError stores a Target that could not be analyzed, with the reason
+func (rs *ReportSink) AddError(target *Target, err error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.targetReport(target).Error = err.Error()
+}
+
+// Report returns the collected report
+func (rs *ReportSink) Report() *Report {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	return rs.report
+}
+
+// WriteJSON writes the collected report as an indented JSON document
+func (rs *ReportSink) WriteJSON(writer io.Writer) error {
+	data, err := json.MarshalIndent(rs.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
+
+func (rs *ReportSink) targetReport(target *Target) *TargetReport {
+	if targetReport, ok := rs.targets[target]; ok {
+		return targetReport
+	}
+
+	targetReport := &TargetReport{
+		Target:  target,
+		Results: make([]*Result, 0),
+	}
+	if target == nil {
+		targetReport.Target = &Target{}
+	}
+	rs.targets[target] = targetReport
+	rs.report.Targets = append(rs.report.Targets, targetReport)
+
+	return targetReport
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ing-bank/kal/pkg/risk"
+)
+
+// TargetSummary summarizes the analysis of a Target
+type TargetSummary struct {
+	Name             string `json:"name"`
+	Username         string `json:"username,omitempty"`
+	ServerURL        string `json:"serverURL"`
+	Error            string `json:"error,omitempty"`
+	AllowedResources int    `json:"allowedResources"`
+	// HighestSeverity is the severity of the most severe risk finding
+	HighestSeverity      risk.Severity `json:"highestSeverity,omitempty"`
+	DangerousPermissions []string      `json:"dangerousPermissions"`
+}
+
+// Summarize returns the summary of every target of a report, the most severe first
+//
+// The dangerous permissions are the permissions of the risk findings of the targets, set by
+// EvaluateRisk
+func Summarize(report *Report) []*TargetSummary {
+	summaries := make([]*TargetSummary, 0, len(report.Targets))
+
+	for _, targetReport := range report.Targets {
+		ps := NewPermissionSet(targetReport.Results...)
+
+		var highestSeverity risk.Severity
+		dangerous := make(map[string]struct{})
+		for _, finding := range targetReport.Findings {
+			if finding.Severity.Rank() > highestSeverity.Rank() {
+				highestSeverity = finding.Severity
+			}
+
+			for _, permission := range finding.Permissions {
+				dangerous[permission] = struct{}{}
+			}
+		}
+
+		username := ""
+		if targetReport.Identity != nil {
+			username = targetReport.Identity.Username
+		}
+
+		summaries = append(summaries, &TargetSummary{
+			Name:                 targetReport.Name(),
+			Username:             username,
+			ServerURL:            targetReport.ServerURL,
+			Error:                targetReport.Error,
+			AllowedResources:     ps.Len(),
+			HighestSeverity:      highestSeverity,
+			DangerousPermissions: sortedKeys(dangerous),
+		})
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].HighestSeverity.Rank() != summaries[j].HighestSeverity.Rank() {
+			return summaries[i].HighestSeverity.Rank() > summaries[j].HighestSeverity.Rank()
+		}
+		return len(summaries[i].DangerousPermissions) > len(summaries[j].DangerousPermissions)
+	})
+
+	return summaries
+}
+
+// WriteSummaryTable writes the summaries as a table
+func WriteSummaryTable(writer io.Writer, summaries []*TargetSummary) error {
+	tw := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "TARGET\tIDENTITY\tSERVER\tALLOWED RESOURCES\tRISK\tDANGEROUS PERMISSIONS")
+	for _, summary := range summaries {
+		username := summary.Username
+		if username == "" {
+			username = "-"
+		}
+
+		allowed := strconv.Itoa(summary.AllowedResources)
+		severity := string(summary.HighestSeverity)
+		dangerous := strings.Join(summary.DangerousPermissions, ", ")
+
+		if severity == "" {
+			severity = "-"
+		}
+
+		if summary.Error != "" {
+			allowed = "-"
+			dangerous = "ERROR: " + summary.Error
+		} else if dangerous == "" {
+			dangerous = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", summary.Name, username, summary.ServerURL, allowed, severity, dangerous)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// TargetError is the error of the analysis of a Target
+type TargetError struct {
+	Target *Target
+	Err    error
+}
+
+// Error returns the string representation of a TargetError
+func (te *TargetError) Error() string {
+	return fmt.Sprintf("%s: %s", te.Target.Name(), te.Err)
+}
+
+// Unwrap returns the error of the analysis
+func (te *TargetError) Unwrap() error {
+	return te.Err
+}
+
+// execTargets runs the analysis of count targets, at most parallel at the same time
+//
+// A failing target does not stop the analysis of the other ones. The errors are returned in
+// the order of the targets
+func execTargets(count, parallel int, exec func(i int) *TargetError) []*TargetError {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	errs := make([]*TargetError, count)
+
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, parallel)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := exec(i); err != nil {
+				gologger.Error().Msgf("%s\n", err)
+				errs[i] = err
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	targetErrors := make([]*TargetError, 0)
+	for _, err := range errs {
+		if err != nil {
+			targetErrors = append(targetErrors, err)
+		}
+	}
+
+	return targetErrors
+}
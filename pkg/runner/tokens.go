@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/ing-bank/kal/pkg/types"
+	"github.com/projectdiscovery/gologger"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// ExecTokens runs the analysis of every token, with a client per token
+//
+// The tokens are analyzed one after the other, sharing the discovery of the resources. A failing
+// token does not stop the analysis of the other ones, its error is returned as a TargetError
+func ExecTokens(o *types.Options, tokens []*kubernetes.LabeledToken, opts ...Option) []*TargetError {
+	cache := NewDiscoveryCache()
+
+	return execTargets(len(tokens), 1, func(i int) *TargetError {
+		return execToken(o, tokens[i], cache, opts...)
+	})
+}
+
+// execToken runs the analysis of a token
+func execToken(o *types.Options, token *kubernetes.LabeledToken, cache *DiscoveryCache, opts ...Option) *TargetError {
+	// every token has its own copy of the kubernetes options, as the clients update them
+	ko := *o.Kubernetes
+	ko.ApiToken = token.Token
+
+	tokenOptions := *o
+	tokenOptions.Kubernetes = &ko
+
+	var client *k8s.Clientset
+	var err error
+
+	client, err = getCustomClient(&tokenOptions)
+	if client == nil {
+		client, err = getKubeConfigClient(&tokenOptions)
+	}
+
+	if client == nil {
+		return &TargetError{
+			Target: &Target{Label: token.Label, ServerURL: ko.ServerURL, Namespaces: []string{}},
+			Err:    err,
+		}
+	}
+
+	gologger.Info().Msgf("analyzing token %s\n", token.Label)
+
+	tokenOpts := append([]Option{}, opts...)
+	tokenOpts = append(tokenOpts, WithDiscoverer(cache.Discoverer(NewAPIDiscoverer(client.Discovery()))))
+
+	r := newFromOptions(client, &tokenOptions, tokenOpts...)
+	r.Label = token.Label
+	if _, err := r.Exec(); err != nil {
+		target := r.target
+		if target == nil {
+			target = &Target{Label: token.Label, ServerURL: r.ServerURL, Namespaces: []string{}}
+		}
+
+		return &TargetError{Target: target, Err: err}
+	}
+
+	return nil
+}
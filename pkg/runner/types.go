@@ -5,25 +5,76 @@ import (
 	"strings"
 	"sync"
 
+	"golang.org/x/sync/semaphore"
 	v1 "k8s.io/api/authorization/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 const kalUserAgent string = "KAL"
 
 // Runner is the structure holding information about KAL's Runner
 type Runner struct {
-	KubernetesClient *kubernetes.Clientset
-	Namespace        string
+	KubernetesClient kubernetes.Interface
+	Namespaces       []string
+	NamespacesFile   string
+	AllNamespaces    bool
 	Context          context.Context
+	// Impersonation is the identity impersonated by the kubernetes client
+	Impersonation rest.ImpersonationConfig
+
+	// ServerURL is the base url of the kubernetes api
+	ServerURL string
+	// Label names the authentication of the runner in the results
+	Label string
+	// KubeContext and KubeCluster are the kubeconfig context and cluster names used by the client
+	KubeContext string
+	KubeCluster string
 
 	WideOutput bool
-	JSONOutput bool
-	ShowReason bool
-	ShowAll    bool
+	// ShowAll sends to the sink the results without allowed verbs
+	ShowAll bool
+
+	// NonResourceURLs is the list of non-resource URLs provided by the user
+	NonResourceURLs []string
+	// SkipNonResource disables the analysis of non-resource URLs
+	SkipNonResource bool
+
+	// Concurrency is the number of resources analyzed at the same time
+	Concurrency int
+	// MaxRequests is the maximum number of in-flight access review requests, 0 means no limit
+	MaxRequests int
+
+	// AllVerbs tests every API verb in every resource, instead of only the supported ones
+	AllVerbs bool
+
+	// RulesReview enables the SelfSubjectRulesReview enumeration mode
+	RulesReview bool
+
+	// VerifyToken checks the signature of the token before the analysis, with the keys of
+	// the JWKSFile or, when it is empty, of the Kubernetes API
+	VerifyToken bool
+	JWKSFile    string
+
+	// token is the bearer token of the client, used to find the identity on older servers
+	token string
 
-	outputWg   sync.WaitGroup
-	outputChan chan *Result
+	reviewer   AccessReviewer
+	discoverer Discoverer
+	sink       ResultSink
+
+	rulesReviews map[string]*rulesReview
+	requestSem   *semaphore.Weighted
+	target       *Target
+
+	// reviewMutex protects the counters of the access review requests
+	reviewMutex    sync.Mutex
+	reviewRequests int
+	reviewFailures int
+	reviewErr      error
+
+	// cancel cancels the Context created by New, stopping the analysis
+	cancel context.CancelFunc
 }
 
 // Resource is the abstraction of a Kubernetes resource
@@ -34,10 +85,23 @@ type Resource struct {
 	Name         string
 	Namespaced   bool
 	SubResource  string
+	// Verbs is the list of verbs supported by the resource, as reported by the discovery API
+	Verbs []string
+	// NonResourceURL is the path of a non-resource URL, like `/metrics`
+	NonResourceURL string
+}
+
+// IsNonResource returns if the Resource represents a non-resource URL
+func (r *Resource) IsNonResource() bool {
+	return r.NonResourceURL != ""
 }
 
 // String return the string representation of a Resource
 func (r *Resource) String() string {
+	if r.IsNonResource() {
+		return r.NonResourceURL
+	}
+
 	sb := &strings.Builder{}
 
 	sb.WriteString(r.Name)
@@ -58,11 +122,13 @@ func (r *Resource) String() string {
 // Result is the structure used to hold information used to present
 // the analysis result for a user
 type Result struct {
+	// Target is the cluster and authentication the result belongs to
+	Target                         *Target
 	Namespace                      string
 	Resource                       *Resource
 	SelfSubjectAccessReviewResults []*v1.SelfSubjectAccessReview
-	str                            string
 	AllowedVerbs                   []string
+	DeniedVerbs                    []string
 }
 
 // AnalysisResult is the structure that contains the analysis information of a Resource
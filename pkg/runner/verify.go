@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"errors"
+
+	myK8s "github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/projectdiscovery/gologger"
+)
+
+// verifyToken checks if the token of the runner is signed by the service account issuer of the cluster
+//
+// The keys are read from the JWKSFile, or from the Kubernetes API. It returns nil when the token
+// could not be verified
+func (r *Runner) verifyToken() *myK8s.TokenVerification {
+	if r.token == "" {
+		gologger.Warning().Msg("no token to verify")
+		return nil
+	}
+
+	jwks, err := r.jwks()
+	if err != nil {
+		gologger.Error().Msgf("could not get the service account issuer keys. error: %s\n", err)
+		return nil
+	}
+
+	verification := jwks.VerifyToken(r.token)
+
+	// the warnings are logged as errors, to be shown without the verbose output
+	switch {
+	case !verification.Verified:
+		gologger.Error().Msgf("token is not signed by the service account issuer of the cluster. error: %s\n", verification.Error)
+	case verification.IssuerMismatch():
+		gologger.Error().Msgf("token is signed by the cluster, but issued by [%s] instead of [%s]\n", verification.Issuer, verification.ExpectedIssuer)
+	default:
+		gologger.Info().Msgf("token is signed by the service account issuer of the cluster (key %s)\n", verification.KeyID)
+	}
+
+	return verification
+}
+
+// jwks returns the keys of the service account issuer
+func (r *Runner) jwks() (*myK8s.JWKS, error) {
+	if r.JWKSFile != "" {
+		return myK8s.ReadJWKSFile(r.JWKSFile)
+	}
+
+	if r.KubernetesClient == nil {
+		return nil, errors.New("no kubernetes client to get the keys")
+	}
+
+	restClient := r.KubernetesClient.Discovery().RESTClient()
+	if restClient == nil {
+		return nil, errors.New("discovery client has no rest client")
+	}
+
+	return myK8s.FetchJWKS(r.Context, restClient)
+}
@@ -0,0 +1,320 @@
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/ing-bank/kal/pkg/risk"
+)
+
+const (
+	// Version is the version of the SARIF format written by a Log
+	Version = "2.1.0"
+	// Schema is the JSON schema of the SARIF 2.1.0 format
+	Schema = "https://json.schemastore.org/sarif-2.1.0.json"
+	// InformationURI is the home page of KAL, reported as the tool of the runs
+	InformationURI = "https://github.com/ing-bank/kal"
+)
+
+// Log is a SARIF 2.1.0 document with a single run of KAL
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []*Run `json:"runs"`
+
+	// ruleIndexes are the positions of the rules in the driver, by id
+	ruleIndexes map[string]int
+}
+
+// Run is the analysis of the targets by a tool
+type Run struct {
+	Tool    Tool      `json:"tool"`
+	Results []*Result `json:"results"`
+}
+
+// Tool describes the tool of a Run
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool component reporting the results, with its rules
+type Driver struct {
+	Name           string  `json:"name"`
+	Version        string  `json:"version,omitempty"`
+	InformationURI string  `json:"informationUri,omitempty"`
+	Rules          []*Rule `json:"rules"`
+}
+
+// Rule is a reporting descriptor, the SARIF counterpart of a risk.Rule
+type Rule struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name,omitempty"`
+	ShortDescription     Message        `json:"shortDescription"`
+	FullDescription      *Message       `json:"fullDescription,omitempty"`
+	Help                 *Message       `json:"help,omitempty"`
+	DefaultConfiguration Configuration  `json:"defaultConfiguration"`
+	Properties           map[string]any `json:"properties,omitempty"`
+}
+
+// Configuration is the default configuration of a Rule
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+// Message is a plain text message
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is a risk finding of a target
+type Result struct {
+	RuleID string `json:"ruleId"`
+	// RuleIndex is the position of the rule in the driver, unset for the rules it does not describe
+	RuleIndex           *int              `json:"ruleIndex,omitempty"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []*Location       `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+// Location is where a Result was found, a namespace or the cluster of a target
+//
+// Findings are not in files, the physical location is a stable artifact URI of the namespace or
+// cluster, required by code scanning dashboards to show the results
+type Location struct {
+	PhysicalLocation *PhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []*LogicalLocation `json:"logicalLocations"`
+}
+
+// PhysicalLocation is the artifact of a Location
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+}
+
+// ArtifactLocation is the URI of an artifact, relative to the uriBaseId when it is set
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// LogicalLocation is a namespace of a target, or the target cluster for cluster-wide findings
+type LogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// NewLog creates a Log describing the rules of a risk engine, without results
+func NewLog(version string, rules []*risk.Rule) *Log {
+	l := &Log{
+		Version:     Version,
+		Schema:      Schema,
+		ruleIndexes: make(map[string]int, len(rules)),
+	}
+
+	driver := Driver{
+		Name:           "kal",
+		Version:        version,
+		InformationURI: InformationURI,
+		Rules:          make([]*Rule, 0, len(rules)),
+	}
+
+	for _, rule := range rules {
+		l.ruleIndexes[rule.ID] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, newRule(rule))
+	}
+
+	l.Runs = []*Run{{Tool: Tool{Driver: driver}, Results: make([]*Result, 0)}}
+
+	return l
+}
+
+// newRule returns the reporting descriptor of a risk rule
+func newRule(rule *risk.Rule) *Rule {
+	r := &Rule{
+		ID:                   rule.ID,
+		Name:                 ruleName(rule.ID),
+		ShortDescription:     Message{Text: rule.Title},
+		DefaultConfiguration: Configuration{Level: Level(rule.Severity)},
+		Properties: map[string]any{
+			"security-severity": SecuritySeverity(rule.Severity),
+			"severity":          string(rule.Severity),
+			"tags":              []string{"security", "kubernetes", "rbac"},
+		},
+	}
+
+	if rule.Description != "" {
+		r.FullDescription = &Message{Text: rule.Description}
+	}
+	r.Help = &Message{Text: help(rule)}
+
+	return r
+}
+
+// ruleName returns the name of a rule id, like `SecretsReadCluster` for `secrets-read-cluster`
+func ruleName(id string) string {
+	sb := &strings.Builder{}
+	for _, part := range strings.FieldsFunc(id, func(r rune) bool { return r == '-' || r == '_' }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return sb.String()
+}
+
+// help returns the help text of a rule, with its risk and the permissions it matches
+func help(rule *risk.Rule) string {
+	matches := rule.NonResourceURLs
+	if len(rule.Resources) > 0 {
+		matches = make([]string, 0, len(rule.APIGroups)*len(rule.Resources))
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				if group != "" {
+					resource += "." + group
+				}
+				matches = append(matches, resource)
+			}
+		}
+	}
+
+	text := rule.Description
+	if text == "" {
+		text = rule.Title
+	}
+
+	requires := ""
+	for _, req := range rule.Requires {
+		requires += fmt.Sprintf(", with %s on %s", strings.Join(req.Verbs, ", "), strings.Join(req.Resources, ", "))
+	}
+
+	return fmt.Sprintf("%s\n\nMatched permissions: %s on %s%s.\nReview the roles bound to the identity and remove these verbs unless they are required.",
+		text, strings.Join(rule.Verbs, ", "), strings.Join(matches, ", "), requires)
+}
+
+// Level returns the SARIF level of a severity: error, warning or note
+func Level(severity risk.Severity) string {
+	switch severity {
+	case risk.Critical, risk.High:
+		return "error"
+	case risk.Medium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// SecuritySeverity returns the CVSS-like score of a severity, used by code scanning dashboards
+func SecuritySeverity(severity risk.Severity) string {
+	switch severity {
+	case risk.Critical:
+		return "9.5"
+	case risk.High:
+		return "8.0"
+	case risk.Medium:
+		return "5.5"
+	default:
+		return "2.0"
+	}
+}
+
+// clusterURIInvalid matches the characters of a cluster name or url replaced in an artifact URI
+var clusterURIInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ArtifactURI returns the stable artifact URI of a namespace of a cluster, or of the cluster itself
+// for an empty namespace, like `kubernetes/cluster.example.com/namespaces/apps`
+func ArtifactURI(cluster, namespace string) string {
+	if u, err := url.Parse(cluster); err == nil && u.Host != "" {
+		cluster = u.Host
+	}
+
+	cluster = strings.Trim(clusterURIInvalid.ReplaceAllString(cluster, "-"), "-")
+	if cluster == "" {
+		cluster = "cluster"
+	}
+
+	if namespace == "" {
+		return "kubernetes/" + cluster + "/cluster-wide"
+	}
+
+	return "kubernetes/" + cluster + "/namespaces/" + namespace
+}
+
+// newLocation returns the location of a namespace of a cluster, or of the cluster itself
+func newLocation(cluster, namespace string) *Location {
+	logical := &LogicalLocation{Name: cluster, FullyQualifiedName: cluster, Kind: "cluster"}
+	if namespace != "" {
+		logical = &LogicalLocation{Name: namespace, FullyQualifiedName: cluster + "/" + namespace, Kind: "namespace"}
+	}
+
+	return &Location{
+		PhysicalLocation: &PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{URI: ArtifactURI(cluster, namespace)},
+		},
+		LogicalLocations: []*LogicalLocation{logical},
+	}
+}
+
+// AddFindings adds a result per finding of a target, identified by its identity and cluster
+//
+// Every namespace of a finding is a location, and the cluster for cluster-wide findings
+func (l *Log) AddFindings(identity, cluster string, findings []*risk.Finding) {
+	run := l.Runs[0]
+
+	for _, finding := range findings {
+		locations := make([]*Location, 0, len(finding.Namespaces)+1)
+		if finding.ClusterWide {
+			locations = append(locations, newLocation(cluster, ""))
+		}
+		for _, ns := range finding.Namespaces {
+			locations = append(locations, newLocation(cluster, ns))
+		}
+
+		scope := "cluster-wide"
+		if len(finding.Namespaces) > 0 {
+			scope = "in namespaces " + strings.Join(finding.Namespaces, ", ")
+			if finding.ClusterWide {
+				scope = "cluster-wide and " + scope
+			}
+		}
+
+		var ruleIndex *int
+		if index, ok := l.ruleIndexes[finding.RuleID]; ok {
+			ruleIndex = &index
+		}
+
+		run.Results = append(run.Results, &Result{
+			RuleID:    finding.RuleID,
+			RuleIndex: ruleIndex,
+			Level:     Level(finding.Severity),
+			Message: Message{Text: fmt.Sprintf("%s: %s is allowed to %s %s",
+				finding.Title, identity, strings.Join(finding.Permissions, ", "), scope)},
+			Locations: locations,
+			PartialFingerprints: map[string]string{
+				"kalFinding/v1": identity + ":" + cluster + ":" + finding.RuleID,
+			},
+			Properties: map[string]any{
+				"identity":    identity,
+				"cluster":     cluster,
+				"namespaces":  finding.Namespaces,
+				"clusterWide": finding.ClusterWide,
+				"severity":    string(finding.Severity),
+				"permissions": finding.Permissions,
+			},
+		})
+	}
+}
+
+// Write writes the log as an indented JSON document
+func (l *Log) Write(writer io.Writer) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,102 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ing-bank/kal/pkg/risk"
+)
+
+func TestLogAddFindings(t *testing.T) {
+	log := NewLog("v1.0.0", risk.NewEngine().Rules())
+	log.AddFindings("system:serviceaccount:ci:deployer", "prod", []*risk.Finding{
+		{
+			RuleID:      "pod-exec",
+			Title:       "Execute commands in pods",
+			Severity:    risk.High,
+			Permissions: []string{"create pods/exec"},
+			Namespaces:  []string{"apps", "ci"},
+		},
+	})
+
+	buffer := &bytes.Buffer{}
+	if err := log.Write(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &Log{}
+	if err := json.Unmarshal(buffer.Bytes(), decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Version != "2.1.0" || len(decoded.Runs) != 1 || len(decoded.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected log %s", buffer.String())
+	}
+
+	run := decoded.Runs[0]
+	result := run.Results[0]
+	if result.Level != "error" || result.RuleIndex == nil || run.Tool.Driver.Rules[*result.RuleIndex].ID != "pod-exec" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	expected := "Execute commands in pods: system:serviceaccount:ci:deployer is allowed to create pods/exec in namespaces apps, ci"
+	if result.Message.Text != expected {
+		t.Errorf("expected message %q, got %q", expected, result.Message.Text)
+	}
+
+	if len(result.Locations) != 2 {
+		t.Fatalf("expected a location per namespace, got %d", len(result.Locations))
+	}
+
+	for i, expectedURI := range []string{"kubernetes/prod/namespaces/apps", "kubernetes/prod/namespaces/ci"} {
+		location := result.Locations[i]
+		if location.PhysicalLocation == nil || location.PhysicalLocation.ArtifactLocation.URI != expectedURI {
+			t.Errorf("expected the artifact uri %s, got %+v", expectedURI, location.PhysicalLocation)
+		}
+	}
+
+	logical := result.Locations[1].LogicalLocations
+	if len(logical) != 1 || logical[0].FullyQualifiedName != "prod/ci" || logical[0].Kind != "namespace" {
+		t.Errorf("unexpected logical locations %+v", logical)
+	}
+
+	if run.Tool.Driver.Rules[*result.RuleIndex].Help == nil {
+		t.Errorf("expected a help text for rule %s", result.RuleID)
+	}
+}
+
+func TestLogAddFindingsUnknownRule(t *testing.T) {
+	log := NewLog("v1.0.0", nil)
+	log.AddFindings("jane", "prod", []*risk.Finding{
+		{RuleID: "custom", Title: "Custom rule", Severity: risk.Low, Permissions: []string{"get pods"}, ClusterWide: true},
+	})
+
+	buffer := &bytes.Buffer{}
+	if err := log.Write(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(buffer.Bytes(), []byte("ruleIndex")) {
+		t.Errorf("expected no rule index for a rule missing from the driver, got %s", buffer.String())
+	}
+}
+
+func TestArtifactURI(t *testing.T) {
+	tests := []struct {
+		cluster   string
+		namespace string
+		expected  string
+	}{
+		{"https://10.0.0.1:6443", "", "kubernetes/10.0.0.1-6443/cluster-wide"},
+		{"https://cluster.example.com", "kube-system", "kubernetes/cluster.example.com/namespaces/kube-system"},
+		{"kind-kind", "apps", "kubernetes/kind-kind/namespaces/apps"},
+		{"", "apps", "kubernetes/cluster/namespaces/apps"},
+	}
+
+	for _, tt := range tests {
+		if uri := ArtifactURI(tt.cluster, tt.namespace); uri != tt.expected {
+			t.Errorf("expected uri %s, got %s", tt.expected, uri)
+		}
+	}
+}
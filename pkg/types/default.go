@@ -1,5 +1,7 @@
 package types
 
+import "runtime/debug"
+
 // Banner is KAL's banner
 const Banner = `
 ############################
@@ -16,3 +18,13 @@ const Banner = `
 
 // Disclaimer is the legal disclaimer for KAL
 const Disclaimer = "Usage of kal for attacking targets without prior mutual consent is illegal. It is the end user's responsibility to obey all applicable local, state and federal laws. Developers assume no liability and are not responsible for any misuse or damage caused by this program"
+
+// Version returns KAL's version, as recorded in the build information
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return "dev"
+	}
+
+	return info.Main.Version
+}
@@ -1,13 +1,26 @@
 package types
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ing-bank/kal/pkg/kubernetes"
+
 	"github.com/logrusorgru/aurora/v4"
+	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/formatter"
 	"github.com/projectdiscovery/gologger/levels"
 	"k8s.io/client-go/rest"
 )
 
+// NoRateLimitQPS is the QPS and Burst used by the kubernetes client when the rate limit is removed
+const NoRateLimitQPS = 400
+
 // AU is the package to control color in the output
 var AU *aurora.Aurora
 
@@ -15,6 +28,8 @@ var AU *aurora.Aurora
 type Options struct {
 	Kubernetes *KubernetesOptions
 
+	Enumeration *EnumerationOptions
+
 	Output *OutputOptions
 
 	Verbose bool
@@ -29,6 +44,7 @@ func (o *Options) Validate() {
 	}
 
 	o.Kubernetes.Validate()
+	o.Enumeration.Validate()
 	o.Output.Validate()
 }
 
@@ -51,56 +67,325 @@ func (o *Options) Configure() {
 
 // Kubernetes Options is the structure for Kubernetes Options
 type KubernetesOptions struct {
-	ApiToken          string
-	Burst             int
-	InsecureTLS       bool
-	KubeConfigPath    string
-	Namespace         string
-	NoRateLimit       bool
-	QPS               float32
-	ServerURL         string
-	UserToImpersonate string
+	ApiToken string
+	// TokenFile, TokenStdin and ServiceAccountDir are the sources of ApiToken besides the argument
+	TokenFile         string
+	TokenStdin        bool
+	ServiceAccountDir string
+	// TokensFile is the file of the tokens analyzed in batch mode
+	TokensFile string
+	// VerifyToken checks the signature of the token, with the keys of the JWKSFile or of the cluster
+	VerifyToken bool
+	JWKSFile    string
+	Burst       int
+	InsecureTLS bool
+	// ClientCertificate and ClientKey are the paths of the x509 client authentication files
+	ClientCertificate string
+	ClientKey         string
+	// CertificateAuthority is the path of the CA bundle verifying the server certificate
+	CertificateAuthority string
+	TLSServerName        string
+	ProxyURL             string
+	KubeConfigPath       string
+	// KubeContext, KubeCluster and KubeUser override the current context of the kubeconfig
+	KubeContext string
+	KubeCluster string
+	KubeUser    string
+	// AllContexts and Contexts analyze many kubeconfig contexts, ParallelContexts at the same time
+	AllContexts      bool
+	Contexts         goflags.StringSlice
+	ParallelContexts int
+	Namespaces       goflags.StringSlice
+	NamespacesFile   string
+	AllNamespaces    bool
+	NoRateLimit      bool
+	QPS              int
+	ServerURL        string
+
+	UserToImpersonate   string
+	GroupsToImpersonate goflags.StringSlice
+	UIDToImpersonate    string
+	ExtraToImpersonate  goflags.StringSlice
 }
 
 // Validate validates the Kubernetes Options for constraints
 func (ko *KubernetesOptions) Validate() {
-	if ko.ServerURL == "" {
-		gologger.Fatal().Msg("invalid kubernetes server url")
+	if err := ko.loadToken(); err != nil {
+		gologger.Fatal().Msgf("%s", err)
 	}
 
-	if ko.NoRateLimit {
-		ko.QPS = 400
-		ko.Burst = 400
-	} else {
-		ko.QPS = rest.DefaultQPS
+	if ko.AllContexts && len(ko.Contexts) > 0 {
+		gologger.Fatal().Msg("all contexts and a list of contexts selected")
+	}
+
+	if ko.MultipleContexts() && (ko.KubeContext != "" || ko.KubeCluster != "") {
+		gologger.Fatal().Msg("a single kubeconfig context or cluster cannot be selected with many contexts")
+	}
+
+	if ko.MultipleContexts() && (ko.ApiToken != "" || ko.ServerURL != "" || ko.ClientCertificate != "") {
+		gologger.Fatal().Msg("many contexts use the kubeconfig authentication, a token, client certificate or server url cannot be provided")
+	}
+
+	if ko.MultipleContexts() && ko.TokensFile != "" {
+		gologger.Fatal().Msg("tokens file and many contexts selected")
+	}
+
+	if (ko.ApiToken != "" || ko.TokensFile != "") && ko.ClientCertificate != "" {
+		gologger.Fatal().Msg("token and client certificate authentication selected")
+	}
+
+	if (ko.ClientCertificate == "") != (ko.ClientKey == "") {
+		gologger.Fatal().Msg("client certificate and client key must be provided together")
+	}
+
+	if ko.InsecureTLS && ko.CertificateAuthority != "" {
+		gologger.Fatal().Msg("certificate authority and disabled TLS verification selected")
+	}
+
+	if ko.ProxyURL != "" {
+		if proxyURL, err := url.Parse(ko.ProxyURL); err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			gologger.Fatal().Msgf("invalid proxy url [%s]", ko.ProxyURL)
+		}
+	}
+
+	if ko.JWKSFile != "" {
+		ko.VerifyToken = true
+	}
+
+	if ko.ParallelContexts < 1 {
+		ko.ParallelContexts = 1
+	}
+
+	if err := ko.validateImpersonation(); err != nil {
+		gologger.Fatal().Msgf("%s", err)
+	}
+
+	if ko.QPS < 0 || ko.Burst < 0 {
+		gologger.Fatal().Msg("invalid kubernetes client qps or burst")
+	}
+
+	if ko.QPS == 0 {
+		ko.QPS = int(rest.DefaultQPS)
+		if ko.NoRateLimit {
+			ko.QPS = NoRateLimitQPS
+		}
+	}
+
+	if ko.Burst == 0 {
 		ko.Burst = rest.DefaultBurst
+		if ko.NoRateLimit {
+			ko.Burst = NoRateLimitQPS
+		}
+	}
+}
+
+// validateImpersonation checks the groups, uid and extra attributes are impersonated with an user
+func (ko *KubernetesOptions) validateImpersonation() error {
+	if ko.UserToImpersonate == "" &&
+		(len(ko.GroupsToImpersonate) > 0 || ko.UIDToImpersonate != "" || len(ko.ExtraToImpersonate) > 0) {
+		return errors.New("impersonating groups, uid or extra attributes requires an user to impersonate")
+	}
+
+	for _, extra := range ko.ExtraToImpersonate {
+		if key, _, ok := strings.Cut(extra, "="); !ok || key == "" {
+			return fmt.Errorf("invalid extra attribute to impersonate [%s], expected key=value", extra)
+		}
+	}
+
+	return nil
+}
+
+// loadToken sets the token from the selected source, or from the KAL_TOKEN environment variable
+//
+// The environment variable is only used without another authentication, a token, a client
+// certificate or the kubeconfig authentications of many contexts
+func (ko *KubernetesOptions) loadToken() error {
+	sources := 0
+	for _, selected := range []bool{ko.ApiToken != "", ko.TokenFile != "", ko.TokenStdin, ko.ServiceAccountDir != "", ko.TokensFile != ""} {
+		if selected {
+			sources++
+		}
+	}
+
+	if sources > 1 {
+		return errors.New("only one of token, token file, token stdin, service account folder and tokens file can be provided")
 	}
+
+	var err error
+	switch {
+	case ko.TokenFile != "":
+		ko.ApiToken, err = kubernetes.ReadTokenFile(ko.TokenFile)
+	case ko.TokenStdin:
+		ko.ApiToken, err = kubernetes.ReadToken(os.Stdin)
+	case ko.ServiceAccountDir != "":
+		err = ko.loadServiceAccountDir()
+	case ko.ApiToken == "" && ko.TokensFile == "" && ko.ClientCertificate == "" && !ko.MultipleContexts():
+		ko.ApiToken = strings.TrimSpace(os.Getenv(kubernetes.TokenEnvVar))
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not read token. error: %w", err)
+	}
+
+	return nil
+}
+
+// loadServiceAccountDir sets the token, CA and namespace from a service account token folder
+//
+// Like inside a POD, the server url defaults to the kubernetes service environment variables
+func (ko *KubernetesOptions) loadServiceAccountDir() error {
+	saFiles, err := kubernetes.ReadServiceAccountDir(ko.ServiceAccountDir)
+	if err != nil {
+		return err
+	}
+
+	ko.ApiToken = saFiles.Token
+
+	if ko.CertificateAuthority == "" && !ko.InsecureTLS {
+		ko.CertificateAuthority = saFiles.CAFile
+	}
+
+	if len(ko.Namespaces) == 0 && ko.NamespacesFile == "" && saFiles.Namespace != "" {
+		ko.Namespaces = goflags.StringSlice{saFiles.Namespace}
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if ko.ServerURL == "" && host != "" && port != "" {
+		ko.ServerURL = "https://" + net.JoinHostPort(host, port)
+	}
+
+	return nil
 }
 
+// MultipleContexts returns if many kubeconfig contexts are analyzed
+func (ko *KubernetesOptions) MultipleContexts() bool {
+	return ko.AllContexts || len(ko.Contexts) > 0
+}
+
+// Impersonation returns the impersonation configuration for the kubernetes client
+func (ko *KubernetesOptions) Impersonation() rest.ImpersonationConfig {
+	impersonation := rest.ImpersonationConfig{
+		UserName: ko.UserToImpersonate,
+		UID:      ko.UIDToImpersonate,
+		Groups:   ko.GroupsToImpersonate,
+	}
+
+	for _, extra := range ko.ExtraToImpersonate {
+		key, value, _ := strings.Cut(extra, "=")
+		if impersonation.Extra == nil {
+			impersonation.Extra = make(map[string][]string)
+		}
+		impersonation.Extra[key] = append(impersonation.Extra[key], value)
+	}
+
+	return impersonation
+}
+
+// EnumerationOptions is the structure for options related to how permissions are enumerated
+type EnumerationOptions struct {
+	AllVerbs        bool
+	Concurrency     int
+	MaxRequests     int
+	NonResourceURLs goflags.StringSlice
+	RulesReview     bool
+	SkipNonResource bool
+}
+
+// Validate validates the Enumeration Options for constraints
+func (eo *EnumerationOptions) Validate() {
+	if eo.Concurrency < 1 {
+		gologger.Fatal().Msg("invalid concurrency, it must be at least 1")
+	}
+
+	if eo.MaxRequests < 0 {
+		gologger.Fatal().Msg("invalid max requests, it must be positive or 0 for no limit")
+	}
+}
+
+// OutputFormat is the format used to present the results
+type OutputFormat string
+
+const (
+	// TextOutput presents every result as a colored line
+	TextOutput OutputFormat = "text"
+	// JSONOutput presents every result as an indented JSON document
+	JSONOutput OutputFormat = "json"
+	// JSONLinesOutput presents every result as a JSON document in a single line
+	JSONLinesOutput OutputFormat = "jsonl"
+	// JSONAggregateOutput presents all results in a single JSON document, with the execution metadata
+	JSONAggregateOutput OutputFormat = "json-aggregate"
+)
+
 // OutputOptions is the structure for options related to output information
 type OutputOptions struct {
-	JSON       bool
-	NoColor    bool
-	ShowAll    bool
-	ShowReason bool
+	JSON          bool
+	JSONLines     bool
+	JSONAggregate bool
+	NoColor       bool
+	ShowAll       bool
+	ShowReason    bool
+	// RiskRulesFile is the YAML file of custom rules extending the built-in risk catalogue
+	RiskRulesFile string
+	// EscalationDOTFile and EscalationJSONFile are the files of the privilege escalation graph
+	EscalationDOTFile  string
+	EscalationJSONFile string
+	// RBACFile is the file of the Roles and ClusterRoles granting the allowed permissions
+	RBACFile string
+	// SARIFFile is the file of the risk findings in SARIF 2.1.0 format
+	SARIFFile string
+	// HTMLFile is the file of the self-contained HTML report
+	HTMLFile string
+	// ExpectFile is the YAML policy of the expected permissions, ExpectMissing also reports the
+	// expected permissions not allowed
+	ExpectFile    string
+	ExpectMissing bool
 }
 
 // Validate validates the provided Output options
 func (oo *OutputOptions) Validate() {
+	selectedFormats := 0
+	for _, selected := range []bool{oo.JSON, oo.JSONLines, oo.JSONAggregate} {
+		if selected {
+			selectedFormats++
+		}
+	}
+
+	if selectedFormats > 1 {
+		gologger.Fatal().Msg("only one json output format can be selected")
+	}
+
+	if oo.ExpectMissing && oo.ExpectFile == "" {
+		gologger.Fatal().Msg("checking the missing permissions requires an expected permissions policy")
+	}
+
 	gologger.DefaultLogger.SetFormatter(formatter.NewCLI(oo.NoColor))
 
-	if oo.JSON {
+	if oo.Format() != TextOutput {
 		gologger.DefaultLogger.SetFormatter(&formatter.JSON{})
 	}
 }
 
+// Format returns the selected output format
+func (oo *OutputOptions) Format() OutputFormat {
+	switch {
+	case oo.JSON:
+		return JSONOutput
+	case oo.JSONLines:
+		return JSONLinesOutput
+	case oo.JSONAggregate:
+		return JSONAggregateOutput
+	default:
+		return TextOutput
+	}
+}
+
 // InitAurora initialize Aurora for colored logging
 func InitAurora(o *Options) {
 	if AU != nil {
 		return
 	}
 
-	if o.Output.JSON {
+	if o.Output.Format() != TextOutput {
 		AU = aurora.New(aurora.WithColors(false))
 	} else {
 		AU = aurora.New(aurora.WithColors(!o.Output.NoColor))
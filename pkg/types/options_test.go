@@ -0,0 +1,189 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/projectdiscovery/goflags"
+)
+
+func TestLoadToken(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tokenFile := write("token", "\n  file-token \n")
+	stdinFile := write("stdin", "stdin-token\n")
+	write("serviceaccount/token", "sa-token\n")
+	write("serviceaccount/namespace", "apps\n")
+	caFile := write("serviceaccount/ca.crt", "ca")
+	write("token-only/token", "sa-token")
+
+	tests := []struct {
+		name       string
+		options    KubernetesOptions
+		env        map[string]string
+		token      string
+		namespaces []string
+		caFile     string
+		serverURL  string
+		err        bool
+	}{
+		{
+			name:    "token",
+			options: KubernetesOptions{ApiToken: "arg-token"},
+			env:     map[string]string{kubernetes.TokenEnvVar: "env-token"},
+			token:   "arg-token",
+		},
+		{
+			name:    "token file",
+			options: KubernetesOptions{TokenFile: tokenFile},
+			env:     map[string]string{kubernetes.TokenEnvVar: "env-token"},
+			token:   "file-token",
+		},
+		{
+			name:    "missing token file",
+			options: KubernetesOptions{TokenFile: filepath.Join(dir, "missing")},
+			err:     true,
+		},
+		{
+			name:    "token stdin",
+			options: KubernetesOptions{TokenStdin: true},
+			token:   "stdin-token",
+		},
+		{
+			name:       "service account folder",
+			options:    KubernetesOptions{ServiceAccountDir: filepath.Join(dir, "serviceaccount")},
+			env:        map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "KUBERNETES_SERVICE_PORT": "443"},
+			token:      "sa-token",
+			namespaces: []string{"apps"},
+			caFile:     caFile,
+			serverURL:  "https://10.0.0.1:443",
+		},
+		{
+			name:       "service account folder with provided namespace and ca",
+			options:    KubernetesOptions{ServiceAccountDir: filepath.Join(dir, "serviceaccount"), Namespaces: goflags.StringSlice{"default"}, InsecureTLS: true},
+			token:      "sa-token",
+			namespaces: []string{"default"},
+		},
+		{
+			name:    "service account folder with the token only",
+			options: KubernetesOptions{ServiceAccountDir: filepath.Join(dir, "token-only")},
+			token:   "sa-token",
+		},
+		{
+			name:    "service account folder without token",
+			options: KubernetesOptions{ServiceAccountDir: filepath.Join(dir, "missing")},
+			err:     true,
+		},
+		{
+			name:  "environment variable",
+			env:   map[string]string{kubernetes.TokenEnvVar: " env-token\n"},
+			token: "env-token",
+		},
+		{
+			name:    "environment variable with a client certificate",
+			options: KubernetesOptions{ClientCertificate: "client.crt", ClientKey: "client.key"},
+			env:     map[string]string{kubernetes.TokenEnvVar: "env-token"},
+		},
+		{
+			name:    "environment variable with many contexts",
+			options: KubernetesOptions{AllContexts: true},
+			env:     map[string]string{kubernetes.TokenEnvVar: "env-token"},
+		},
+		{
+			name:    "token and token file",
+			options: KubernetesOptions{ApiToken: "arg-token", TokenFile: tokenFile},
+			err:     true,
+		},
+		{
+			name:    "token stdin and service account folder",
+			options: KubernetesOptions{TokenStdin: true, ServiceAccountDir: filepath.Join(dir, "serviceaccount")},
+			err:     true,
+		},
+		{
+			name:    "token file and tokens file",
+			options: KubernetesOptions{TokenFile: tokenFile, TokensFile: tokenFile},
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{kubernetes.TokenEnvVar, "KUBERNETES_SERVICE_HOST", "KUBERNETES_SERVICE_PORT"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			stdin, err := os.Open(stdinFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdin.Close()
+
+			previousStdin := os.Stdin
+			os.Stdin = stdin
+			defer func() { os.Stdin = previousStdin }()
+
+			ko := tt.options
+			err = ko.loadToken()
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if ko.ApiToken != tt.token {
+				t.Errorf("expected token %q, got %q", tt.token, ko.ApiToken)
+			}
+
+			if len(tt.namespaces) > 0 || len(ko.Namespaces) > 0 {
+				if !reflect.DeepEqual([]string(ko.Namespaces), tt.namespaces) {
+					t.Errorf("expected namespaces %v, got %v", tt.namespaces, ko.Namespaces)
+				}
+			}
+
+			if ko.CertificateAuthority != tt.caFile || ko.ServerURL != tt.serverURL {
+				t.Errorf("expected ca %q and server %q, got %q and %q", tt.caFile, tt.serverURL, ko.CertificateAuthority, ko.ServerURL)
+			}
+		})
+	}
+}
+
+func TestValidateImpersonation(t *testing.T) {
+	tests := []struct {
+		name    string
+		options KubernetesOptions
+		valid   bool
+	}{
+		{"no impersonation", KubernetesOptions{}, true},
+		{"user", KubernetesOptions{UserToImpersonate: "jane", GroupsToImpersonate: goflags.StringSlice{"dev"}, UIDToImpersonate: "42", ExtraToImpersonate: goflags.StringSlice{"scopes=view"}}, true},
+		{"groups without user", KubernetesOptions{GroupsToImpersonate: goflags.StringSlice{"dev"}}, false},
+		{"uid without user", KubernetesOptions{UIDToImpersonate: "42"}, false},
+		{"extra without user", KubernetesOptions{ExtraToImpersonate: goflags.StringSlice{"scopes=view"}}, false},
+		{"extra without value", KubernetesOptions{UserToImpersonate: "jane", ExtraToImpersonate: goflags.StringSlice{"scopes"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.options.validateImpersonation(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %t, got error %v", tt.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ing-bank/kal/pkg/kubernetes"
+	"github.com/ing-bank/kal/pkg/runner"
+	"github.com/ing-bank/kal/pkg/types"
+	"github.com/projectdiscovery/goflags"
+	"github.com/projectdiscovery/gologger"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// tokenInspectOptions are the options of the `kal token inspect` subcommand
+type tokenInspectOptions struct {
+	Token         string
+	TokenFile     string
+	TokenStdin    bool
+	CheckBindings bool
+	VerifyToken   bool
+	JWKSFile      string
+	JSON          bool
+	NoColor       bool
+
+	// Kubernetes is the authentication used to check the bindings, not the inspected token
+	Kubernetes *types.KubernetesOptions
+}
+
+// tokenCommand runs the `kal token` subcommands
+func tokenCommand(args []string) {
+	if len(args) == 0 || args[0] != "inspect" {
+		gologger.Fatal().Msg("unknown token subcommand, expected: kal token inspect")
+	}
+
+	o := &tokenInspectOptions{Kubernetes: &types.KubernetesOptions{}}
+
+	set := goflags.NewFlagSet()
+	set.SetDescription("kal token inspect decodes every claim of a JWT token and looks for weaknesses")
+	set.StringVar(&o.Token, "token", "", "token to inspect (default $KAL_TOKEN)")
+	set.StringVarP(&o.TokenFile, "token-file", "tf", "", "file with the token to inspect")
+	set.BoolVarP(&o.TokenStdin, "token-stdin", "ts", false, "read the token to inspect from stdin")
+	set.BoolVarP(&o.CheckBindings, "check-bindings", "cb", false, "check if the bound pod and secret still exist, using the kubeconfig authentication")
+	set.BoolVarP(&o.VerifyToken, "verify-token", "vt", false, "verify the token signature with the service account issuer keys of the cluster, using the kubeconfig authentication")
+	set.StringVar(&o.JWKSFile, "jwks-file", "", "verify the token signature with the keys of a jwks file, for offline use")
+	set.StringVarP(&o.Kubernetes.KubeConfigPath, "config", "c", "", "path to kubeconfig file used to check the bindings and get the keys")
+	set.StringVar(&o.Kubernetes.KubeContext, "context", "", "kubeconfig context used to check the bindings and get the keys")
+	set.BoolVarP(&o.JSON, "json", "j", false, "output the inspection as a json document")
+	set.BoolVarP(&o.NoColor, "no-color", "nc", false, "no color output")
+	_ = set.Parse(args[1:]...)
+
+	options.Output.NoColor = o.NoColor
+	if o.JSON {
+		options.Output.JSON = true
+	}
+	options.Configure()
+
+	tk, err := o.readToken()
+	if err != nil {
+		gologger.Fatal().Msgf("could not read token. error: %s\n", err)
+	}
+
+	inspection, err := kubernetes.InspectToken(tk, time.Now())
+	if err != nil {
+		gologger.Fatal().Msgf("could not inspect token. error: %s\n", err)
+	}
+
+	var client *k8s.Clientset
+	if o.CheckBindings || (o.VerifyToken && o.JWKSFile == "") {
+		client, err = runner.NewClient(&types.Options{Kubernetes: o.Kubernetes})
+		if err != nil {
+			gologger.Fatal().Msgf("could not create kubernetes client. error: %s\n", err)
+		}
+	}
+
+	if o.CheckBindings {
+		if err := inspection.CheckBindings(context.Background(), client); err != nil {
+			gologger.Error().Msgf("could not check token bindings. error: %s\n", err)
+		}
+	}
+
+	if o.VerifyToken || o.JWKSFile != "" {
+		jwks, err := o.jwks(client)
+		if err != nil {
+			gologger.Error().Msgf("could not get the service account issuer keys. error: %s\n", err)
+		} else {
+			inspection.Verify(jwks.VerifyToken(tk))
+		}
+	}
+
+	if o.JSON {
+		err = writeInspectionJSON(os.Stdout, inspection)
+	} else {
+		err = writeInspection(os.Stdout, inspection)
+	}
+	if err != nil {
+		gologger.Fatal().Msgf("could not write inspection. error: %s\n", err)
+	}
+}
+
+// readToken reads the token to inspect from the selected source
+func (o *tokenInspectOptions) readToken() (string, error) {
+	switch {
+	case o.TokenFile != "":
+		return kubernetes.ReadTokenFile(o.TokenFile)
+	case o.TokenStdin:
+		return kubernetes.ReadToken(os.Stdin)
+	case o.Token != "":
+		return strings.TrimSpace(o.Token), nil
+	case os.Getenv(kubernetes.TokenEnvVar) != "":
+		return strings.TrimSpace(os.Getenv(kubernetes.TokenEnvVar)), nil
+	default:
+		return "", fmt.Errorf("no token provided")
+	}
+}
+
+// jwks returns the keys of the service account issuer, from the jwks file or the kubernetes api
+func (o *tokenInspectOptions) jwks(client *k8s.Clientset) (*kubernetes.JWKS, error) {
+	if o.JWKSFile != "" {
+		return kubernetes.ReadJWKSFile(o.JWKSFile)
+	}
+
+	return kubernetes.FetchJWKS(context.Background(), client.Discovery().RESTClient())
+}
+
+func writeInspectionJSON(writer io.Writer, inspection *kubernetes.TokenInspection) error {
+	data, err := json.MarshalIndent(inspection, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
+
+func writeInspection(writer io.Writer, inspection *kubernetes.TokenInspection) error {
+	tw := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s\t%s\n", name, value)
+		}
+	}
+
+	row("algorithm", inspection.Algorithm)
+	row("key id", inspection.KeyID)
+	row("issuer", inspection.Issuer)
+	row("audiences", strings.Join(inspection.Audiences, ", "))
+	row("subject", inspection.Subject)
+	row("issued at", formatTime(inspection.IssuedAt))
+	row("not before", formatTime(inspection.NotBefore))
+
+	switch {
+	case inspection.ExpiresAt == nil:
+		row("expires at", types.AU.Red("never").String())
+	case inspection.IsExpired():
+		row("expires at", formatTime(inspection.ExpiresAt)+" "+types.AU.Red("(expired "+strings.TrimPrefix(inspection.ExpiresIn, "-")+" ago)").String())
+	default:
+		row("expires at", formatTime(inspection.ExpiresAt)+" "+types.AU.Green("(in "+inspection.ExpiresIn+")").String())
+	}
+
+	row("namespace", inspection.Namespace)
+	row("service account", inspection.ServiceAccountName)
+	row("service account uid", inspection.ServiceAccountUID)
+	row("pod", inspection.PodName)
+	row("pod uid", inspection.PodUID)
+	row("node", inspection.NodeName)
+	row("node uid", inspection.NodeUID)
+	row("secret", inspection.SecretName)
+
+	if verification := inspection.Verification; verification != nil {
+		switch {
+		case !verification.Verified:
+			row("signature", types.AU.Red("not verified: "+verification.Error).String())
+		case verification.IssuerMismatch():
+			row("signature", types.AU.Yellow("verified, issued by another issuer than "+verification.ExpectedIssuer).String())
+		default:
+			row("signature", types.AU.Green("verified with key "+verification.KeyID).String())
+		}
+	}
+
+	for _, name := range inspection.ClaimNames() {
+		data, _ := json.Marshal(inspection.Claims[name])
+		row("claim", name+" = "+string(data))
+	}
+
+	for _, finding := range inspection.Findings {
+		row("finding", types.AU.Yellow(finding).String())
+	}
+
+	return tw.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}